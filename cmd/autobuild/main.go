@@ -1,26 +1,39 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"strings"
 
 	"github.com/ListenOcean/goHookTool/internal/build"
 	"github.com/ListenOcean/goHookTool/internal/build/log"
+	"github.com/ListenOcean/goHookTool/internal/config"
+	"github.com/ListenOcean/goHookTool/internal/diff"
+	"github.com/ListenOcean/goHookTool/internal/doctor"
+	"github.com/ListenOcean/goHookTool/internal/plan"
 	"github.com/ListenOcean/goHookTool/internal/toolexec"
 
 	"github.com/spf13/cobra"
 )
 
 var rootCmd = &cobra.Command{
-	Use:               "autobuild",
-	Short:             "Build a go application with hook.",
-	TraverseChildren:  true,
+	Use:              "autobuild",
+	Short:            "Build a go application with hook.",
+	TraverseChildren: true,
+	// Printed by main, except the go command errors it already printed.
+	SilenceErrors:     true,
 	DisableAutoGenTag: true,
 }
 
 func init() {
 	rootCmd.AddCommand(toolexec.ToolexecCmd)
 	rootCmd.AddCommand(build.BuildCmd)
+	rootCmd.AddCommand(build.EnvCmd)
+	rootCmd.AddCommand(plan.PlanCmd)
+	rootCmd.AddCommand(diff.DiffCmd)
+	rootCmd.AddCommand(doctor.DoctorCmd)
+	rootCmd.AddCommand(config.ConfigCmd)
 }
 
 var NeedLog bool
@@ -39,8 +52,27 @@ func main() {
 		log.Debug("Program Args.", log.String("args", strings.Join(os.Args, ", ")))
 	}
 
-	_ = rootCmd.Execute()
+	if args, ok := toolexec.DirectInvocationArgs(os.Args[1:]); ok {
+		// Run by the go command with GOFLAGS=-toolexec=autobuild
+		rootCmd.SetArgs(args)
+	}
+	err := rootCmd.Execute()
 	// 同步日志，有检查可以直接调
 	log.Sync()
 	log.Clear()
+	os.Exit(exitCode(err))
+}
+
+// exitCode returns the exit status of autobuild: the one of the go build
+// command when it failed, whose errors were already shown.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var passthroughErr *build.PassthroughError
+	if errors.As(err, &passthroughErr) {
+		return passthroughErr.ExitCode()
+	}
+	fmt.Fprintln(os.Stderr, "Error:", err)
+	return 1
 }
@@ -15,6 +15,19 @@ const (
 	PrologVarIdentPrefix = `_hook_prolog_var_`
 	PrologVarIdentFormat = PrologVarIdentPrefix + `%s`
 
+	// 代码片段 imports 的包名，%s 为规范化的包路径
+	SnippetImportIdentFormat = `_hook_import_%s`
+
+	// 调用点插桩生成的包装函数名，%s 为调用点 Hook ID
+	CallsiteWrapperIdentFormat = `_hook_wrapper_%s`
+	// 调用点 Hook ID 中分隔目标函数与调用方包的部分
+	CallsiteIDSeparator = `__callsite_`
+	// init 函数 Hook ID 中分隔 init 函数与所在文件及序号的部分
+	InitIDSeparator = `__file_`
+
+	// 泛型函数以及泛型类型的方法的签名中的类型参数，与运行时的符号名一致，如 `pkg.Map[...]`、`pkg.(*List[...]).Push`
+	TypeParamsSignatrue = `[...]`
+
 	PrologVarIdent           = "_prolog"
 	PrologAbortErrorVarIdent = "_prolog_abort_err"
 	EpilogVarIdent           = "_epilog"
@@ -23,22 +36,6 @@ const (
 	Version = "0.0.1"
 )
 
-var RuntimeExtraFileContent = `package runtime
-
-import (
-	"runtime/internal/atomic"
-	"unsafe" // also required for go:linkname
-)
-
-// 指针读取
-
-//go:linkname _atomic_load_pointer _atomic_load_pointer
-//go:nosplit
-func _atomic_load_pointer(addr unsafe.Pointer) unsafe.Pointer {
-	return atomic.Loadp(addr)
-}
-`
-
 const CodeTemplate = `package a
 
 func main(){
@@ -0,0 +1,78 @@
+package configs
+
+import (
+	"go/token"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 调用点插桩的函数，pkgname => 函数名 => 签名。这些函数无法在定义处插桩（如被忽略的标准库包），
+// 改为将被插桩包中对它们的调用替换为生成的包装函数的调用
+var CallsiteMap = map[string]map[string]string{}
+
+// HasCallsites 返回是否配置了调用点插桩
+func HasCallsites() bool {
+	return len(CallsiteMap) > 0
+}
+
+// LookupCallsite 返回包 pkgPath 的函数 name 的调用点插桩签名
+func LookupCallsite(pkgPath, name string) (signatrue string, ok bool) {
+	signatrue, ok = CallsiteMap[pkgPath][name]
+	return signatrue, ok
+}
+
+// CallsiteID 返回调用方包 callerID 中函数 targetID 的调用点 Hook ID。目标函数的 ID 在前，
+// 使得同一个函数的调用点在 Hook 表中相邻
+func CallsiteID(targetID, callerID string) string {
+	return targetID + CallsiteIDSeparator + callerID
+}
+
+// CallsiteTargetID 返回调用点 Hook ID 中目标函数的 ID，其它 Hook ID 原样返回
+func CallsiteTargetID(id string) string {
+	if i := strings.Index(id, CallsiteIDSeparator); i >= 0 {
+		return id[:i]
+	}
+	return id
+}
+
+// IsCallsiteInMainPackage 返回调用点 Hook ID 的调用方是否为 main 包
+func IsCallsiteInMainPackage(id string) bool {
+	return strings.HasSuffix(id, CallsiteIDSeparator+"main")
+}
+
+func newCallsiteMap(callsites map[string][]string) map[string]map[string]string {
+	m := make(map[string]map[string]string, len(callsites))
+	for pkgPath, signatrues := range callsites {
+		m[pkgPath] = make(map[string]string, len(signatrues))
+		for _, signatrue := range signatrues {
+			m[pkgPath][strings.TrimPrefix(signatrue, pkgPath+".")] = signatrue
+		}
+	}
+	return m
+}
+
+func (v *validator) validateCallsites(pkgNode, list *yaml.Node) {
+	pkgPath := pkgNode.Value
+	if IsPackageWildcard(pkgPath) {
+		v.errorf(pkgNode, "callsites of package wildcard `%s`: callsites only support package paths", pkgPath)
+		return
+	}
+	if v.callsites == nil {
+		v.callsites = make(map[string]bool)
+	}
+	for _, node := range list.Content {
+		signatrue := node.Value
+		if !strings.HasPrefix(signatrue, pkgPath+".") {
+			v.errorf(node, "callsite `%s` does not belong to package `%s`", signatrue, pkgPath)
+			continue
+		}
+		if name := strings.TrimPrefix(signatrue, pkgPath+"."); !token.IsIdentifier(name) || !token.IsExported(name) {
+			v.errorf(node, "callsite `%s` is not an exported function: %s", signatrue, callsiteUsage)
+			continue
+		}
+		v.callsites[signatrue] = true
+	}
+}
+
+const callsiteUsage = "expecting `<package>.<Func>`, methods and patterns are not supported"
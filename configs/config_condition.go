@@ -0,0 +1,202 @@
+package configs
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Section 为带条件的配置段，条件满足时其 Hook 点与代码片段合并到配置中
+type Section struct {
+	When       Condition           `yaml:"when"`
+	Hookpoints map[string][]string `yaml:"hookpoints"`
+	Codes      map[string]Code     `yaml:"codes"`
+}
+
+// Condition on the target environment of the compilation. Every given key
+// must be satisfied.
+type Condition struct {
+	// Go version constraints, eg. `>=1.20` or `>=1.17 <1.21`.
+	Go     string     `yaml:"go"`
+	GOOS   StringList `yaml:"goos"`
+	GOARCH StringList `yaml:"goarch"`
+	// Build tags that must all be set, or unset when prefixed with `!`.
+	Tags StringList `yaml:"tags"`
+}
+
+// StringList is a list of strings also accepting a single scalar value.
+type StringList []string
+
+func (l *StringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = StringList{node.Value}
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+var conditionKeys = map[string]struct{}{
+	"go":     {},
+	"goos":   {},
+	"goarch": {},
+	"tags":   {},
+}
+
+func (c *Condition) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: condition must be a mapping", node.Line)
+	}
+	for i := 0; i < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if _, ok := conditionKeys[key.Value]; !ok {
+			return fmt.Errorf("line %d: unknown condition key `%s` (expected go, goos, goarch or tags)", key.Line, key.Value)
+		}
+	}
+	type plain Condition
+	if err := node.Decode((*plain)(c)); err != nil {
+		return err
+	}
+	if _, err := parseGoConstraints(c.Go); err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	return nil
+}
+
+// Target 为编译的目标环境，用于判断配置段的条件
+type Target struct {
+	GoVersion string
+	GOOS      string
+	GOARCH    string
+	Tags      []string
+}
+
+// TargetFromEnv returns the target environment given by the go command to the
+// tools it runs, and the given build tags.
+func TargetFromEnv(tags []string) Target {
+	target := Target{
+		GoVersion: os.Getenv("GOVERSION"),
+		GOOS:      os.Getenv("GOOS"),
+		GOARCH:    os.Getenv("GOARCH"),
+		Tags:      tags,
+	}
+	if target.GOOS == "" {
+		target.GOOS = runtime.GOOS
+	}
+	if target.GOARCH == "" {
+		target.GOARCH = runtime.GOARCH
+	}
+	return target
+}
+
+// Match 判断目标环境是否满足条件
+func (c *Condition) Match(target Target) bool {
+	if len(c.GOOS) > 0 && !contains(c.GOOS, target.GOOS) {
+		return false
+	}
+	if len(c.GOARCH) > 0 && !contains(c.GOARCH, target.GOARCH) {
+		return false
+	}
+	for _, tag := range c.Tags {
+		if negated := strings.TrimPrefix(tag, "!"); negated != tag {
+			if contains(target.Tags, negated) {
+				return false
+			}
+		} else if !contains(target.Tags, tag) {
+			return false
+		}
+	}
+	if c.Go != "" {
+		version, ok := ParseGoVersion(target.GoVersion)
+		if !ok {
+			// The version is unknown: the constraint cannot be satisfied.
+			return false
+		}
+		constraints, _ := parseGoConstraints(c.Go)
+		for _, constraint := range constraints {
+			if !constraint.match(version) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func contains(list []string, s string) bool {
+	for _, each := range list {
+		if each == s {
+			return true
+		}
+	}
+	return false
+}
+
+type goConstraint struct {
+	op      string
+	version [3]int
+	// Number of version components given, the others being ignored.
+	parts int
+}
+
+var goConstraintOps = []string{">=", "<=", "==", "!=", ">", "<", "="}
+
+// parseGoConstraints parses the space or comma separated Go version
+// constraints, eg. `>=1.17, <1.21`. A version without operator must be equal.
+func parseGoConstraints(s string) ([]goConstraint, error) {
+	var constraints []goConstraint
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == ' ' || r == ','
+	}) {
+		constraint := goConstraint{op: "=="}
+		for _, op := range goConstraintOps {
+			if strings.HasPrefix(field, op) {
+				constraint.op = op
+				field = strings.TrimPrefix(field, op)
+				break
+			}
+		}
+		if constraint.op == "=" {
+			constraint.op = "=="
+		}
+		version, ok := ParseGoVersion("go" + strings.TrimPrefix(field, "go"))
+		if !ok || strings.Count(field, ".") > 2 {
+			return nil, fmt.Errorf("invalid go version constraint `%s`", field)
+		}
+		constraint.version = version
+		constraint.parts = strings.Count(field, ".") + 1
+		constraints = append(constraints, constraint)
+	}
+	return constraints, nil
+}
+
+func (c goConstraint) match(version [3]int) bool {
+	cmp := 0
+	for i := 0; i < c.parts && cmp == 0; i++ {
+		if version[i] < c.version[i] {
+			cmp = -1
+		} else if version[i] > c.version[i] {
+			cmp = 1
+		}
+	}
+	switch c.op {
+	case ">=":
+		return cmp >= 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case "<":
+		return cmp < 0
+	case "!=":
+		return cmp != 0
+	default:
+		return cmp == 0
+	}
+}
@@ -0,0 +1,87 @@
+package configs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGoConstraints(t *testing.T) {
+	for _, tc := range []struct {
+		constraints string
+		want        []goConstraint
+		wantErr     bool
+	}{
+		{constraints: "", want: nil},
+		{constraints: "1.21", want: []goConstraint{{op: "==", version: [3]int{1, 21}, parts: 2}}},
+		{constraints: "go1.21", want: []goConstraint{{op: "==", version: [3]int{1, 21}, parts: 2}}},
+		{constraints: "=1.21.3", want: []goConstraint{{op: "==", version: [3]int{1, 21, 3}, parts: 3}}},
+		{constraints: "!=1", want: []goConstraint{{op: "!=", version: [3]int{1}, parts: 1}}},
+		{
+			constraints: ">=1.17 <1.21",
+			want: []goConstraint{
+				{op: ">=", version: [3]int{1, 17}, parts: 2},
+				{op: "<", version: [3]int{1, 21}, parts: 2},
+			},
+		},
+		{
+			constraints: ">1.17, <=go1.21.0",
+			want: []goConstraint{
+				{op: ">", version: [3]int{1, 17}, parts: 2},
+				{op: "<=", version: [3]int{1, 21, 0}, parts: 3},
+			},
+		},
+		{constraints: "1.21.3.4", wantErr: true},
+		{constraints: "=>1.21", wantErr: true},
+		{constraints: ">=2.0", wantErr: true},
+		{constraints: ">=latest", wantErr: true},
+		{constraints: ">=1.17 <", wantErr: true},
+	} {
+		t.Run(tc.constraints, func(t *testing.T) {
+			got, err := parseGoConstraints(tc.constraints)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("got %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConditionMatchGoVersion(t *testing.T) {
+	for _, tc := range []struct {
+		constraints string
+		goVersion   string
+		want        bool
+	}{
+		{constraints: "1.21", goVersion: "go1.21.3", want: true},
+		{constraints: "1.21", goVersion: "go1.22", want: false},
+		{constraints: "1.21.3", goVersion: "go1.21.3", want: true},
+		{constraints: "1.21.3", goVersion: "go1.21.4", want: false},
+		{constraints: ">=1.20", goVersion: "go1.20rc1", want: true},
+		{constraints: ">=1.20", goVersion: "go1.19.13", want: false},
+		{constraints: ">1.20", goVersion: "go1.20.5", want: false},
+		{constraints: ">1.20.4", goVersion: "go1.20.5", want: true},
+		{constraints: "<1.21", goVersion: "go1.21.0", want: false},
+		{constraints: "<=1.21", goVersion: "go1.21.9", want: true},
+		{constraints: "!=1.21", goVersion: "go1.21.9", want: false},
+		{constraints: ">=1.17 <1.21", goVersion: "go1.18", want: true},
+		{constraints: ">=1.17 <1.21", goVersion: "go1.21.1", want: false},
+		{constraints: ">=1.22", goVersion: "devel go1.23-c1e48a8 Wed Feb 7 18:27:01 2024 +0000", want: true},
+		// The version is unknown.
+		{constraints: ">=1.17", goVersion: "", want: false},
+	} {
+		t.Run(tc.constraints+"/"+tc.goVersion, func(t *testing.T) {
+			c := Condition{Go: tc.constraints}
+			if got := c.Match(Target{GoVersion: tc.goVersion}); got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
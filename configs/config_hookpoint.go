@@ -1,27 +1,170 @@
 package configs
 
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
 type Config struct {
+	// 先于本文件合并的配置文件，相对路径基于本文件所在目录
+	Include StringList `yaml:"include,omitempty"`
+	// 从之前合并的配置中移除的 Hook 点与代码片段
+	Remove     Remove              `yaml:"remove,omitempty"`
 	Hookpoints map[string][]string `yaml:"hookpoints"`
+	// 在调用处插桩的函数，见 CallsiteMap
+	Callsites map[string][]string `yaml:"callsites,omitempty"`
+	// 接口方法 Hook 点，见 InterfaceMethods
+	Interfaces map[string][]string `yaml:"interfaces,omitempty"`
 	Codes      map[string]Code     `yaml:"codes"`
+	// 具名的代码片段模板，由 codes 通过 use 引用
+	Templates map[string]Template `yaml:"templates,omitempty"`
+	// 严格模式：存在未被插桩的 Hook 点时构建失败，未设置时沿用之前合并的配置
+	Strict *bool `yaml:"strict,omitempty"`
+	// 带条件的配置段，按顺序合并，后面的代码片段覆盖前面的
+	Sections []Section `yaml:"sections,omitempty"`
+	// 插桩的包范围
+	Packages Packages `yaml:"packages,omitempty"`
+}
+
+// Packages 为忽略与允许插桩的包，支持 `...` 通配符
+type Packages struct {
+	// 不插桩的包，与 DefaultIgnoredPackages 一起生效
+	Ignore []string `yaml:"ignore,omitempty"`
+	// 即使被忽略也插桩的包，优先于 Ignore 以及 DefaultIgnoredPackages
+	Allow []string `yaml:"allow,omitempty"`
 }
 
 type Code struct {
-	Epilog string `yaml:"epilog"`
-	Prolog string `yaml:"prolog"`
+	Epilog string `yaml:"epilog,omitempty"`
+	Prolog string `yaml:"prolog,omitempty"`
+	// 使用的代码片段模板，未指定的 prolog/epilog 使用模板中的代码片段
+	Use string `yaml:"use,omitempty"`
+	// 模板参数，代码片段中通过 {{.With.<参数名>}} 引用
+	With map[string]string `yaml:"with,omitempty"`
+	// 代码片段引用的包，见 SnippetImport
+	Imports StringList `yaml:"imports,omitempty"`
+	// 内置的 Hook 类型，见 HookKinds
+	Kind string `yaml:"kind,omitempty"`
 }
 
 var ConfigData Config
 
-// Hook点，pkgname =>set of signatrue
+// ErrNoConfig 表示未指定配置文件
+var ErrNoConfig = errors.New("no config file")
+
+// Hook点，pkgname =>set of signatrue，包括通配符与正则表达式（见HookPointPatterns）
 var HookPointMap = map[string]map[string]struct{}{}
 
-// default构建器时（不等于main、runtime的包）会忽略的前缀
-var IgnoredPkgPrefixes = []string{
-	"runtime", // 用于忽略runtime里面的所有包，例如runtime/internal/xxx也会进入default构建器逻辑
-	"sync",
-	"reflect",
-	"internal",
+// default构建器时（不等于main、runtime的包）默认忽略的包，`...` 通配符与 go 命令的包模式一致
+var DefaultIgnoredPackages = []string{
+	"runtime/...", // 用于忽略runtime里面的所有包，例如runtime/internal/xxx也会进入default构建器逻辑
+	"sync/...",
+	"reflect/...",
+	"internal/...",
 	"unsafe",
-	"syscall",
-	"time",
+	"syscall/...",
+	"time/...",
+}
+
+// ReadConfig 读取配置文件，按顺序合并各配置文件及其 include 的文件（每个文件先合并满足目标环境条件的配置段），并生成HookPointMap。
+// configFile 可以为以 os.PathListSeparator 分隔的多个文件，为空时使用CUSTOMCONFIG指定的配置文件
+func ReadConfig(configFile string, target Target) error {
+	resetConfig()
+	if configFile == "" {
+		configFile = os.Getenv(TagCustomConfig)
+	}
+	files := SplitConfigFiles(configFile)
+	if len(files) == 0 {
+		return ErrNoConfig
+	}
+	layers, err := loadConfigLayers(files)
+	if err != nil {
+		return err
+	}
+	if errs, _ := splitWarnings(validateLayers(layers)); len(errs) > 0 {
+		return &ValidationError{Diagnostics: errs}
+	}
+	for _, layer := range layers {
+		var config Config
+		if err := yaml.Unmarshal(layer.data, &config); err != nil {
+			return fmt.Errorf("%s: %w", layer.filename, err)
+		}
+		config.mergeSections(target)
+		ConfigData.merge(&config)
+	}
+	if err := ConfigData.resolveTemplates(); err != nil {
+		return err
+	}
+	// convert to HookPointMap, HookPointPatterns and PackageWildcards
+	for key, values := range ConfigData.Hookpoints {
+		if IsPackageWildcard(key) {
+			w := newPackageWildcard(key, values)
+			for _, value := range values {
+				if !IsHookpointPattern(value) {
+					continue
+				}
+				// Check the syntax, with the pattern itself as package path.
+				if _, err := CompileHookpointPattern(w.Expand(key, value)); err != nil {
+					return err
+				}
+			}
+			PackageWildcards = append(PackageWildcards, w)
+			continue
+		}
+		HookPointMap[key] = make(map[string]struct{})
+		for _, value := range values {
+			HookPointMap[key][value] = struct{}{}
+			if !IsHookpointPattern(value) {
+				continue
+			}
+			pattern, err := CompileHookpointPattern(value)
+			if err != nil {
+				return err
+			}
+			HookPointPatterns[key] = append(HookPointPatterns[key], pattern)
+		}
+	}
+	CallsiteMap = newCallsiteMap(ConfigData.Callsites)
+	InterfaceMethods = newInterfaceMethods(ConfigData.Interfaces)
+	sort.Slice(PackageWildcards, func(i, j int) bool {
+		return PackageWildcards[i].Pattern < PackageWildcards[j].Pattern
+	})
+	return nil
+}
+
+// resetConfig clears the config read by a previous call of ReadConfig.
+func resetConfig() {
+	ConfigData = Config{}
+	HookPointMap = map[string]map[string]struct{}{}
+	HookPointPatterns = map[string][]*HookpointPattern{}
+	PackageWildcards = nil
+	expandedPatterns = map[string]*HookpointPattern{}
+	CallsiteMap = map[string]map[string]string{}
+	InterfaceMethods = map[string][]InterfaceMethod{}
+}
+
+// mergeSections merges the sections matching the target into the config.
+func (c *Config) mergeSections(target Target) {
+	for i := range c.Sections {
+		section := &c.Sections[i]
+		if !section.When.Match(target) {
+			continue
+		}
+		if c.Hookpoints == nil {
+			c.Hookpoints = make(map[string][]string)
+		}
+		for pkg, signatrues := range section.Hookpoints {
+			c.Hookpoints[pkg] = append(c.Hookpoints[pkg], signatrues...)
+		}
+		if c.Codes == nil {
+			c.Codes = make(map[string]Code)
+		}
+		for signatrue, code := range section.Codes {
+			c.Codes[signatrue] = code
+		}
+	}
 }
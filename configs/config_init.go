@@ -0,0 +1,38 @@
+package configs
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// init 函数的函数名。包的 init 函数默认不插桩，只有配置了签名 `<包路径>.init` 的 Hook 点
+// （通配符包中为 `init`）时插桩该包的所有 init 函数，通配符与正则表达式不会匹配 init 函数
+const InitFuncName = "init"
+
+// InitID 返回文件 filename 中第 index 个（从 0 开始）init 函数的 Hook ID。包的所有 init 函数的签名均为
+// `<包路径>.init`，Hook ID 由 init 函数的 ID 加上所在文件及序号组成，使得它们在 Hook 表中相邻
+func InitID(initID, filename string, index int) string {
+	return fmt.Sprintf("%s%s%s_%d", initID, InitIDSeparator, normalizedFilename.ReplaceAllString(filename, "_"), index)
+}
+
+var normalizedFilename = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// InitTargetID 返回 init 函数 Hook ID 中 init 函数的 ID，其它 Hook ID 原样返回
+func InitTargetID(id string) string {
+	if i := strings.Index(id, InitIDSeparator); i >= 0 {
+		return id[:i]
+	}
+	return id
+}
+
+// IsInitHookpoint 返回是否配置了包 pkgPath 的 init 函数 Hook 点
+func IsInitHookpoint(pkgPath string) bool {
+	signatrue := pkgPath + "." + InitFuncName
+	for _, hookpoint := range MatchHookpoints(pkgPath, signatrue) {
+		if hookpoint == signatrue {
+			return true
+		}
+	}
+	return false
+}
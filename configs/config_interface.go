@@ -0,0 +1,86 @@
+package configs
+
+import (
+	"go/token"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// InterfaceMethod 为接口方法 Hook 点，插桩所有实现该接口的类型的该方法
+type InterfaceMethod struct {
+	// 签名，如 `io.Reader.Read`
+	Signatrue string
+	// 接口所在的包，如 `io`
+	PkgPath string
+	// 接口名，如 `Reader`
+	Interface string
+	// 方法名，如 `Read`
+	Method string
+}
+
+// 接口方法 Hook 点，pkgname => 接口方法
+var InterfaceMethods = map[string][]InterfaceMethod{}
+
+// HasInterfaces 返回是否配置了接口方法 Hook 点
+func HasInterfaces() bool {
+	return len(InterfaceMethods) > 0
+}
+
+// InterfaceMethodNames 返回接口方法 Hook 点的方法名集合
+func InterfaceMethodNames() map[string]bool {
+	names := make(map[string]bool)
+	for _, methods := range InterfaceMethods {
+		for _, m := range methods {
+			names[m.Method] = true
+		}
+	}
+	return names
+}
+
+// ParseInterfaceMethod 解析包 pkgPath 的接口方法签名 `<包路径>.<接口名>.<方法名>`
+func ParseInterfaceMethod(pkgPath, signatrue string) (InterfaceMethod, bool) {
+	if !strings.HasPrefix(signatrue, pkgPath+".") {
+		return InterfaceMethod{}, false
+	}
+	iface, method, ok := strings.Cut(strings.TrimPrefix(signatrue, pkgPath+"."), ".")
+	if !ok || !token.IsIdentifier(iface) || !token.IsIdentifier(method) {
+		return InterfaceMethod{}, false
+	}
+	return InterfaceMethod{Signatrue: signatrue, PkgPath: pkgPath, Interface: iface, Method: method}, true
+}
+
+func newInterfaceMethods(interfaces map[string][]string) map[string][]InterfaceMethod {
+	m := make(map[string][]InterfaceMethod, len(interfaces))
+	for pkgPath, signatrues := range interfaces {
+		for _, signatrue := range signatrues {
+			if method, ok := ParseInterfaceMethod(pkgPath, signatrue); ok {
+				m[pkgPath] = append(m[pkgPath], method)
+			}
+		}
+	}
+	return m
+}
+
+func (v *validator) validateInterfaces(pkgNode, list *yaml.Node) {
+	pkgPath := pkgNode.Value
+	if IsPackageWildcard(pkgPath) {
+		v.errorf(pkgNode, "interfaces of package wildcard `%s`: interfaces only support package paths", pkgPath)
+		return
+	}
+	if v.interfaces == nil {
+		v.interfaces = make(map[string]bool)
+	}
+	for _, node := range list.Content {
+		signatrue := node.Value
+		if !strings.HasPrefix(signatrue, pkgPath+".") {
+			v.errorf(node, "interface method `%s` does not belong to package `%s`", signatrue, pkgPath)
+			continue
+		}
+		if _, ok := ParseInterfaceMethod(pkgPath, signatrue); !ok {
+			v.errorf(node, "invalid interface method `%s`: expecting `<package>.<Interface>.<Method>`, patterns are not supported", signatrue)
+			continue
+		}
+		v.interfaces[signatrue] = true
+	}
+}
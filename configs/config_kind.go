@@ -0,0 +1,68 @@
+package configs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// HookKind 为内置的 Hook 类型，由 codes 中的 `kind` 指定，插桩时直接生成代码，不需要通过 hooklib 挂载 prolog
+type HookKind struct {
+	// 插入函数开头的代码片段模板，见 SnippetData
+	Code string
+	// 包级别声明的模板
+	Decls string
+	// 代码片段引用的包，见 SnippetImport
+	Imports []string
+	// 代码片段直接返回，不再调用挂载的 prolog
+	Returns bool
+}
+
+// HookKinds 为内置的 Hook 类型
+var HookKinds = map[string]HookKind{
+	// 将函数签名及参数输出到 stderr
+	"log-args": {
+		Code:    `fmt.Fprintln(os.Stderr, "autobuild: {{.Symbol}}"{{range .Params}}, {{.Name}}{{end}})`,
+		Imports: []string{"fmt", "os"},
+	},
+	// 统计调用次数，第 1、2、4、8... 次调用时输出到 stderr
+	"count": {
+		Code: `if _hook_count := atomic.AddUint64(&_hook_count_{{.ID}}, 1); _hook_count&(_hook_count-1) == 0 {
+	println("autobuild: {{.Symbol}} called", _hook_count, "times")
+}`,
+		Decls:   `var _hook_count_{{.ID}} uint64`,
+		Imports: []string{"sync/atomic"},
+	},
+	// 将函数的进入、退出及耗时输出到 stderr
+	"trace": {
+		Code: `_hook_trace_start := time.Now()
+println("autobuild: enter {{.Symbol}}")
+defer func() { println("autobuild: exit {{.Symbol}}", time.Since(_hook_trace_start).String()) }()`,
+		Imports: []string{"time"},
+	},
+	// 不执行函数，直接返回零值；最后一个返回值为 error 时返回错误
+	"deny": {
+		Code: `{{if .ErrorResult}}{{.ErrorResult}} = errors.New("autobuild: {{.Symbol}} denied")
+{{end}}return`,
+		Imports: []string{"errors"},
+		Returns: true,
+	},
+}
+
+// LookupHookKind returns the built-in hook kind of the given name.
+func LookupHookKind(name string) (HookKind, error) {
+	kind, ok := HookKinds[name]
+	if !ok {
+		return kind, fmt.Errorf("unknown hook kind `%s`, expected one of %s", name, strings.Join(hookKindNames(), ", "))
+	}
+	return kind, nil
+}
+
+func hookKindNames() []string {
+	names := make([]string, 0, len(HookKinds))
+	for name := range HookKinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,196 @@
+package configs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Remove 为从之前合并的配置（之前的配置文件以及本文件 include 的文件）中移除的内容
+type Remove struct {
+	// 移除的 Hook 点，未列出 Hook 点的包移除该包的所有 Hook 点
+	Hookpoints map[string][]string `yaml:"hookpoints"`
+	// 移除的调用点插桩函数，规则同 Hookpoints
+	Callsites map[string][]string `yaml:"callsites"`
+	// 移除的接口方法 Hook 点，规则同 Hookpoints
+	Interfaces map[string][]string `yaml:"interfaces"`
+	// 移除的代码片段
+	Codes []string `yaml:"codes"`
+}
+
+// SplitConfigFiles 拆分以 os.PathListSeparator 分隔的配置文件列表
+func SplitConfigFiles(configFile string) []string {
+	var files []string
+	for _, file := range filepath.SplitList(configFile) {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// ConfigFiles 返回配置文件及其 include 的文件，按合并顺序排列
+func ConfigFiles(configFile string) ([]string, error) {
+	layers, err := loadConfigLayers(SplitConfigFiles(configFile))
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, layer := range layers {
+		files = append(files, layer.filename)
+	}
+	return files, nil
+}
+
+// configLayer is a config file, in the order they are merged.
+type configLayer struct {
+	filename string
+	data     []byte
+}
+
+// loadConfigLayers reads the config files and the files they include, the
+// included files preceding the file including them. A file included several
+// times is only merged the first time.
+func loadConfigLayers(files []string) ([]configLayer, error) {
+	l := &layerLoader{loaded: make(map[string]bool)}
+	for _, file := range files {
+		if err := l.load(file, nil); err != nil {
+			return nil, err
+		}
+	}
+	return l.layers, nil
+}
+
+type layerLoader struct {
+	layers []configLayer
+	loaded map[string]bool
+}
+
+func (l *layerLoader) load(filename string, stack []string) error {
+	filename = filepath.Clean(filename)
+	for i, including := range stack {
+		if including == filename {
+			return fmt.Errorf("config include cycle: %s", strings.Join(append(stack[i:], filename), " -> "))
+		}
+	}
+	if l.loaded[filename] {
+		return nil
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var includes struct {
+		Include StringList `yaml:"include"`
+	}
+	if err := yaml.Unmarshal(data, &includes); err != nil {
+		return &ValidationError{Diagnostics: []Diagnostic{yamlDiagnostic(filename, err.Error())}}
+	}
+	for _, include := range includes.Include {
+		// Relative to the including file.
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(filename), include)
+		}
+		if err := l.load(include, append(stack, filename)); err != nil {
+			return err
+		}
+	}
+	l.loaded[filename] = true
+	l.layers = append(l.layers, configLayer{filename: filename, data: data})
+	return nil
+}
+
+// merge merges the config of the next layer, whose sections are already
+// merged: its removals apply first, then the hookpoints are added to the
+// previous ones and its code snippets, templates and strict mode override the
+// previous ones.
+func (c *Config) merge(layer *Config) {
+	if c.Hookpoints == nil {
+		c.Hookpoints = make(map[string][]string)
+	}
+	if c.Codes == nil {
+		c.Codes = make(map[string]Code)
+	}
+	if c.Callsites == nil {
+		c.Callsites = make(map[string][]string)
+	}
+	if c.Interfaces == nil {
+		c.Interfaces = make(map[string][]string)
+	}
+	removeSignatrues(c.Hookpoints, layer.Remove.Hookpoints)
+	removeSignatrues(c.Callsites, layer.Remove.Callsites)
+	removeSignatrues(c.Interfaces, layer.Remove.Interfaces)
+	for _, signatrue := range layer.Remove.Codes {
+		delete(c.Codes, signatrue)
+	}
+
+	for pkg, signatrues := range layer.Hookpoints {
+		c.Hookpoints[pkg] = appendMissing(c.Hookpoints[pkg], signatrues...)
+	}
+	for signatrue, code := range layer.Codes {
+		c.Codes[signatrue] = code
+	}
+	for pkg, signatrues := range layer.Callsites {
+		c.Callsites[pkg] = appendMissing(c.Callsites[pkg], signatrues...)
+	}
+	for pkg, signatrues := range layer.Interfaces {
+		c.Interfaces[pkg] = appendMissing(c.Interfaces[pkg], signatrues...)
+	}
+	if c.Templates == nil {
+		c.Templates = make(map[string]Template)
+	}
+	for name, template := range layer.Templates {
+		c.Templates[name] = template
+	}
+	if layer.Strict != nil {
+		c.Strict = layer.Strict
+	}
+	c.Packages.Ignore = appendMissing(c.Packages.Ignore, layer.Packages.Ignore...)
+	c.Packages.Allow = appendMissing(c.Packages.Allow, layer.Packages.Allow...)
+}
+
+// removeSignatrues removes the signatures from the lists of the packages, or
+// the whole packages when no signature is given, and the packages left empty.
+func removeSignatrues(m map[string][]string, removals map[string][]string) {
+	for pkg, signatrues := range removals {
+		if len(signatrues) == 0 {
+			delete(m, pkg)
+			continue
+		}
+		m[pkg] = removeStrings(m[pkg], signatrues)
+		if len(m[pkg]) == 0 {
+			delete(m, pkg)
+		}
+	}
+}
+
+func appendMissing(list []string, values ...string) []string {
+	for _, value := range values {
+		if !containsString(list, value) {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+func removeStrings(list []string, values []string) []string {
+	var kept []string
+	for _, value := range list {
+		if !containsString(values, value) {
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
+
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,233 @@
+package configs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestConfigMerge(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		layers     []string
+		hookpoints map[string][]string
+		callsites  map[string][]string
+		interfaces map[string][]string
+		codes      map[string]string
+		strict     *bool
+		ignore     []string
+	}{
+		{
+			name: "hookpoints are added once",
+			layers: []string{
+				`hookpoints: {os: [os.Open, os.Create]}`,
+				`hookpoints: {os: [os.Create, os.Remove], net: [net.Dial]}`,
+			},
+			hookpoints: map[string][]string{"os": {"os.Open", "os.Create", "os.Remove"}, "net": {"net.Dial"}},
+		},
+		{
+			name: "codes override previous ones",
+			layers: []string{
+				`codes: {os.Open: {prolog: a}, os.Create: {prolog: b}}`,
+				`codes: {os.Open: {prolog: c}}`,
+			},
+			codes: map[string]string{"os.Open": "c", "os.Create": "b"},
+		},
+		{
+			name: "remove signatures",
+			layers: []string{
+				`{hookpoints: {os: [os.Open, os.Create]}, callsites: {time: [time.Now, time.Since]}, interfaces: {io: [io.Reader.Read]}}`,
+				`remove: {hookpoints: {os: [os.Create]}, callsites: {time: [time.Now]}, interfaces: {io: [io.Reader.Read]}}`,
+			},
+			hookpoints: map[string][]string{"os": {"os.Open"}},
+			callsites:  map[string][]string{"time": {"time.Since"}},
+			interfaces: map[string][]string{},
+		},
+		{
+			name: "remove packages",
+			layers: []string{
+				`{hookpoints: {os: [os.Open], net: [net.Dial]}, callsites: {time: [time.Now]}}`,
+				`remove: {hookpoints: {os: []}, callsites: {time: }}`,
+			},
+			hookpoints: map[string][]string{"net": {"net.Dial"}},
+			callsites:  map[string][]string{},
+		},
+		{
+			name: "removals apply before the additions of the layer",
+			layers: []string{
+				`hookpoints: {os: [os.Open, os.Create]}`,
+				`{remove: {hookpoints: {os: []}}, hookpoints: {os: [os.Remove]}}`,
+			},
+			hookpoints: map[string][]string{"os": {"os.Remove"}},
+		},
+		{
+			name: "remove codes",
+			layers: []string{
+				`codes: {os.Open: {prolog: a}, os.Create: {prolog: b}}`,
+				`remove: {codes: [os.Open, os.Remove]}`,
+			},
+			codes: map[string]string{"os.Create": "b"},
+		},
+		{
+			name: "packages accumulate",
+			layers: []string{
+				`packages: {ignore: [vendor/...]}`,
+				`packages: {ignore: [vendor/..., internal/...]}`,
+			},
+			ignore: []string{"vendor/...", "internal/..."},
+		},
+		{
+			name: "strict is kept when unset",
+			layers: []string{
+				`strict: true`,
+				`hookpoints: {}`,
+			},
+			strict: &[]bool{true}[0],
+		},
+		{
+			name: "strict is overridden",
+			layers: []string{
+				`strict: true`,
+				`strict: false`,
+			},
+			strict: &[]bool{false}[0],
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var merged Config
+			for _, data := range tc.layers {
+				var layer Config
+				if err := yaml.Unmarshal([]byte(data), &layer); err != nil {
+					t.Fatal(err)
+				}
+				merged.merge(&layer)
+			}
+			if tc.hookpoints == nil {
+				tc.hookpoints = map[string][]string{}
+			}
+			if !reflect.DeepEqual(merged.Hookpoints, tc.hookpoints) {
+				t.Errorf("got hookpoints %v, want %v", merged.Hookpoints, tc.hookpoints)
+			}
+			if tc.callsites == nil {
+				tc.callsites = map[string][]string{}
+			}
+			if !reflect.DeepEqual(merged.Callsites, tc.callsites) {
+				t.Errorf("got callsites %v, want %v", merged.Callsites, tc.callsites)
+			}
+			if tc.interfaces == nil {
+				tc.interfaces = map[string][]string{}
+			}
+			if !reflect.DeepEqual(merged.Interfaces, tc.interfaces) {
+				t.Errorf("got interfaces %v, want %v", merged.Interfaces, tc.interfaces)
+			}
+			codes := map[string]string{}
+			for signatrue, code := range merged.Codes {
+				codes[signatrue] = code.Prolog
+			}
+			if tc.codes == nil {
+				tc.codes = map[string]string{}
+			}
+			if !reflect.DeepEqual(codes, tc.codes) {
+				t.Errorf("got codes %v, want %v", codes, tc.codes)
+			}
+			if !reflect.DeepEqual(merged.Strict, tc.strict) {
+				t.Errorf("got strict %v, want %v", fmtStrict(merged.Strict), fmtStrict(tc.strict))
+			}
+			if !reflect.DeepEqual(merged.Packages.Ignore, tc.ignore) {
+				t.Errorf("got ignored packages %v, want %v", merged.Packages.Ignore, tc.ignore)
+			}
+		})
+	}
+}
+
+func fmtStrict(strict *bool) string {
+	if strict == nil {
+		return "unset"
+	}
+	return strconv.FormatBool(*strict)
+}
+
+func TestLoadConfigLayers(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		files   map[string]string
+		configs []string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "included files first",
+			files: map[string]string{
+				"app.yaml":         "include: [base/base.yaml, extra.yaml]",
+				"base/base.yaml":   "include: common.yaml",
+				"base/common.yaml": "",
+				"extra.yaml":       "",
+				"unrelated.yaml":   "",
+			},
+			configs: []string{"app.yaml"},
+			want:    []string{"base/common.yaml", "base/base.yaml", "extra.yaml", "app.yaml"},
+		},
+		{
+			name: "files included several times are merged once",
+			files: map[string]string{
+				"a.yaml":    "include: base.yaml",
+				"b.yaml":    "include: [base.yaml, a.yaml]",
+				"base.yaml": "",
+			},
+			configs: []string{"a.yaml", "b.yaml"},
+			want:    []string{"base.yaml", "a.yaml", "b.yaml"},
+		},
+		{
+			name: "include cycle",
+			files: map[string]string{
+				"a.yaml": "include: b.yaml",
+				"b.yaml": "include: a.yaml",
+			},
+			configs: []string{"a.yaml"},
+			wantErr: "config include cycle",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, data := range tc.files {
+				filename := filepath.Join(dir, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filename, []byte(data), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var configs []string
+			for _, config := range tc.configs {
+				configs = append(configs, filepath.Join(dir, config))
+			}
+			layers, err := loadConfigLayers(configs)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, layer := range layers {
+				rel, err := filepath.Rel(dir, layer.filename)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, filepath.ToSlash(rel))
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got layers %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package configs
+
+import (
+	"regexp"
+	"strings"
+)
+
+// PackageWildcard 为通配符包的 Hook 点配置，如 `github.com/ourorg/...`，
+// 其 Hook 点为相对于匹配的包的函数签名（如 `New*`、`(*Client).Do`）
+type PackageWildcard struct {
+	// Package pattern as written in the configuration.
+	Pattern    string
+	Hookpoints []string
+	re         *regexp.Regexp
+}
+
+// 通配符包，按配置中的顺序
+var PackageWildcards []*PackageWildcard
+
+// Compiled patterns of the hookpoints expanded for packages matching a
+// wildcard, by expanded hookpoint.
+var expandedPatterns = map[string]*HookpointPattern{}
+
+// IsPackageWildcard 判断包名是否为通配符包，`...` 匹配任意字符串，与 go 命令的包模式一致
+func IsPackageWildcard(pkgPath string) bool {
+	return strings.Contains(pkgPath, "...")
+}
+
+func newPackageWildcard(pattern string, hookpoints []string) *PackageWildcard {
+	return &PackageWildcard{
+		Pattern:    pattern,
+		Hookpoints: hookpoints,
+		re:         packagePatternRegexp(pattern),
+	}
+}
+
+// packagePatternRegexp returns the regular expression of a package pattern,
+// matching whole path segments.
+func packagePatternRegexp(pattern string) *regexp.Regexp {
+	expr := strings.ReplaceAll(regexp.QuoteMeta(pattern), `\.\.\.`, `.*`)
+	// As with the go command, `net/...` also matches `net`.
+	if strings.HasSuffix(expr, `/.*`) {
+		expr = strings.TrimSuffix(expr, `/.*`) + `(/.*)?`
+	}
+	return regexp.MustCompile(`^` + expr + `$`)
+}
+
+// Compiled package patterns of the ignore and allow lists.
+var packagePatterns = map[string]*regexp.Regexp{}
+
+// MatchPackagePattern 判断包是否匹配包模式（包名或 `...` 通配符）
+func MatchPackagePattern(pattern, pkgPath string) bool {
+	if !IsPackageWildcard(pattern) {
+		return pattern == pkgPath
+	}
+	re, ok := packagePatterns[pattern]
+	if !ok {
+		re = packagePatternRegexp(pattern)
+		packagePatterns[pattern] = re
+	}
+	return re.MatchString(pkgPath)
+}
+
+// IgnoredPackage 返回忽略该包的包模式：包匹配 DefaultIgnoredPackages 或配置的 ignore 列表，且不匹配 allow 列表
+func IgnoredPackage(pkgPath string) (pattern string, ignored bool) {
+	for _, allowed := range ConfigData.Packages.Allow {
+		if MatchPackagePattern(allowed, pkgPath) {
+			return "", false
+		}
+	}
+	for _, list := range [][]string{DefaultIgnoredPackages, ConfigData.Packages.Ignore} {
+		for _, pattern := range list {
+			if MatchPackagePattern(pattern, pkgPath) {
+				return pattern, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Match 判断包是否匹配
+func (w *PackageWildcard) Match(pkgPath string) bool {
+	return w.re.MatchString(pkgPath)
+}
+
+// Expand returns the hookpoint of the matched package for the given hookpoint
+// of the wildcard, relative to the package: the function signature, or the
+// pattern matching the package function signatures.
+func (w *PackageWildcard) Expand(pkgPath, hookpoint string) string {
+	if expr := strings.TrimPrefix(hookpoint, RegexpHookpointPrefix); expr != hookpoint {
+		return RegexpHookpointPrefix + "^" + regexp.QuoteMeta(pkgPath+".") + "(?:" + strings.TrimPrefix(expr, "^") + ")"
+	}
+	return pkgPath + "." + hookpoint
+}
+
+// HasHookpoints 判断包是否配置了 Hook 点（包括通配符包）
+func HasHookpoints(pkgPath string) bool {
+	if _, ok := HookPointMap[pkgPath]; ok {
+		return true
+	}
+	for _, w := range PackageWildcards {
+		if w.Match(pkgPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// PackageHookpoints 返回包的所有 Hook 点，其中通配符包的 Hook 点展开为该包的函数签名
+func PackageHookpoints(pkgPath string) []string {
+	hookpoints := make([]string, 0, len(HookPointMap[pkgPath]))
+	for hookpoint := range HookPointMap[pkgPath] {
+		hookpoints = append(hookpoints, hookpoint)
+	}
+	for _, w := range PackageWildcards {
+		if !w.Match(pkgPath) {
+			continue
+		}
+		for _, hookpoint := range w.Hookpoints {
+			hookpoints = append(hookpoints, w.Expand(pkgPath, hookpoint))
+		}
+	}
+	return hookpoints
+}
+
+// matchWildcardHookpoints returns the expanded hookpoints of the wildcards the
+// package function signature matches.
+func matchWildcardHookpoints(pkgPath, signatrue string) (hookpoints []string) {
+	for _, w := range PackageWildcards {
+		if !w.Match(pkgPath) {
+			continue
+		}
+		for _, hookpoint := range w.Hookpoints {
+			expanded := w.Expand(pkgPath, hookpoint)
+			if !IsHookpointPattern(expanded) {
+				if expanded == signatrue {
+					hookpoints = append(hookpoints, expanded)
+				}
+				continue
+			}
+			pattern, ok := expandedPatterns[expanded]
+			if !ok {
+				// Already checked when reading the config.
+				pattern, _ = CompileHookpointPattern(expanded)
+				expandedPatterns[expanded] = pattern
+			}
+			if pattern != nil && pattern.Match(signatrue) {
+				hookpoints = append(hookpoints, expanded)
+			}
+		}
+	}
+	return hookpoints
+}
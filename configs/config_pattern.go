@@ -0,0 +1,83 @@
+package configs
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 正则表达式 Hook 点的前缀
+const RegexpHookpointPrefix = "re:"
+
+// Hook点的通配符及正则表达式，pkgname => patterns
+var HookPointPatterns = map[string][]*HookpointPattern{}
+
+// HookpointPattern 为匹配多个函数签名的 Hook 点：
+// 通配符中 `*` 匹配任意字符串、`?` 匹配任意单个字符，`(*` 中的 `*` 为指针接收者；
+// `re:` 前缀表示正则表达式
+type HookpointPattern struct {
+	// Pattern as written in the configuration.
+	Source string
+	re     *regexp.Regexp
+}
+
+// IsHookpointPattern 判断 Hook 点是否为通配符或正则表达式
+func IsHookpointPattern(hookpoint string) bool {
+	if strings.HasPrefix(hookpoint, RegexpHookpointPrefix) {
+		return true
+	}
+	return strings.ContainsRune(strings.ReplaceAll(hookpoint, "(*", "("), '*') ||
+		strings.ContainsRune(hookpoint, '?')
+}
+
+// CompileHookpointPattern compiles the glob or regular expression pattern of a
+// hookpoint.
+func CompileHookpointPattern(hookpoint string) (*HookpointPattern, error) {
+	expr := strings.TrimPrefix(hookpoint, RegexpHookpointPrefix)
+	if expr == hookpoint {
+		expr = globToRegexp(hookpoint)
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hookpoint pattern `%s`: %w", hookpoint, err)
+	}
+	return &HookpointPattern{Source: hookpoint, re: re}, nil
+}
+
+func globToRegexp(glob string) string {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case c == '*' && i > 0 && glob[i-1] == '(':
+			// Pointer receiver
+			expr.WriteString(`\*`)
+		case c == '*':
+			expr.WriteString(".*")
+		case c == '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+	return expr.String()
+}
+
+// Match 判断函数签名是否匹配
+func (p *HookpointPattern) Match(signatrue string) bool {
+	return p.re.MatchString(signatrue)
+}
+
+// MatchHookpoints 返回包中函数签名匹配的所有 Hook 点：签名本身或匹配的通配符、正则表达式
+func MatchHookpoints(pkgPath, signatrue string) (hookpoints []string) {
+	if _, ok := HookPointMap[pkgPath][signatrue]; ok {
+		hookpoints = append(hookpoints, signatrue)
+	}
+	for _, pattern := range HookPointPatterns[pkgPath] {
+		if pattern.Match(signatrue) {
+			hookpoints = append(hookpoints, pattern.Source)
+		}
+	}
+	return append(hookpoints, matchWildcardHookpoints(pkgPath, signatrue)...)
+}
@@ -0,0 +1,128 @@
+package configs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsHookpointPattern(t *testing.T) {
+	for _, tc := range []struct {
+		hookpoint string
+		want      bool
+	}{
+		{hookpoint: "net/http.(*Client).Do", want: false},
+		{hookpoint: "os.Open", want: false},
+		{hookpoint: "os.Open*", want: true},
+		{hookpoint: "os.Ope?", want: true},
+		{hookpoint: "net/http.(*Client).*", want: true},
+		{hookpoint: "net/http.(*Client*).Do", want: true},
+		{hookpoint: "re:^os\\.Open$", want: true},
+	} {
+		t.Run(tc.hookpoint, func(t *testing.T) {
+			if got := IsHookpointPattern(tc.hookpoint); got != tc.want {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCompileHookpointPattern(t *testing.T) {
+	for _, tc := range []struct {
+		pattern string
+		match   []string
+		noMatch []string
+		wantErr bool
+	}{
+		{
+			pattern: "os.Open*",
+			match:   []string{"os.Open", "os.OpenFile"},
+			noMatch: []string{"os.(*File).Open", "xos.Open", "os.open"},
+		},
+		{
+			pattern: "os.Ope?",
+			match:   []string{"os.Open"},
+			noMatch: []string{"os.Ope", "os.OpenFile"},
+		},
+		{
+			pattern: "net/http.(*Client).*",
+			match:   []string{"net/http.(*Client).Do", "net/http.(*Client).Get"},
+			noMatch: []string{"net/http.Client.Do", "net/http.(*Transport).RoundTrip"},
+		},
+		{
+			pattern: "net/http.(*Client*).Do",
+			match:   []string{"net/http.(*Client).Do", "net/http.(*ClientConn).Do"},
+			noMatch: []string{"net/http.(Client).Do"},
+		},
+		{
+			// Regular expression metacharacters of globs are literal.
+			pattern: "a.b+c.F*",
+			match:   []string{"a.b+c.F", "a.b+c.Func"},
+			noMatch: []string{"a.bbc.F", "aXb+c.F"},
+		},
+		{
+			pattern: `re:^os\.(Open|Create)$`,
+			match:   []string{"os.Open", "os.Create"},
+			noMatch: []string{"os.OpenFile"},
+		},
+		{
+			// Regular expressions are not anchored.
+			pattern: `re:Handle`,
+			match:   []string{"pkg.Handle", "pkg.(*Server).HandleFunc"},
+			noMatch: []string{"pkg.handle"},
+		},
+		{pattern: "re:(", wantErr: true},
+		{pattern: "re:a[", wantErr: true},
+	} {
+		t.Run(tc.pattern, func(t *testing.T) {
+			p, err := CompileHookpointPattern(tc.pattern)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("got no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Source != tc.pattern {
+				t.Errorf("got source %q, want %q", p.Source, tc.pattern)
+			}
+			for _, signatrue := range tc.match {
+				if !p.Match(signatrue) {
+					t.Errorf("`%s` doesn't match", signatrue)
+				}
+			}
+			for _, signatrue := range tc.noMatch {
+				if p.Match(signatrue) {
+					t.Errorf("`%s` matches", signatrue)
+				}
+			}
+		})
+	}
+}
+
+func TestReadConfigTwice(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config.yaml")
+	data := []byte(`hookpoints:
+  os: ["os.Open*"]
+  example.com/...: ["New*"]
+`)
+	if err := os.WriteFile(config, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := ReadConfig(config, Target{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(HookPointPatterns["os"]); n != 1 {
+		t.Errorf("got %d patterns, want 1", n)
+	}
+	if n := len(PackageWildcards); n != 1 {
+		t.Errorf("got %d package wildcards, want 1", n)
+	}
+	if hookpoints := MatchHookpoints("os", "os.OpenFile"); len(hookpoints) != 1 {
+		t.Errorf("got hookpoints %q, want one", hookpoints)
+	}
+}
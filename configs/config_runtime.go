@@ -0,0 +1,98 @@
+package configs
+
+import (
+	"strconv"
+	"strings"
+)
+
+// RuntimeExtension is the runtime extension file `autobuild.go` added to the
+// runtime package, for the Go versions from MinGoVersion.
+type RuntimeExtension struct {
+	MinGoVersion string
+	Content      string
+}
+
+// runtime 扩展文件，按支持的最低 Go 版本从新到旧排列
+var RuntimeExtensions = []RuntimeExtension{
+	{
+		// runtime/internal/atomic moved to internal/runtime/atomic
+		MinGoVersion: "go1.23",
+		Content:      runtimeExtraFileContent("internal/runtime/atomic"),
+	},
+	{
+		MinGoVersion: "",
+		Content:      runtimeExtraFileContent("runtime/internal/atomic"),
+	},
+}
+
+func runtimeExtraFileContent(atomicPkgPath string) string {
+	return `package runtime
+
+import (
+	"` + atomicPkgPath + `"
+	"unsafe" // also required for go:linkname
+)
+
+// 指针读取
+
+//go:linkname _atomic_load_pointer _atomic_load_pointer
+//go:nosplit
+func _atomic_load_pointer(addr unsafe.Pointer) unsafe.Pointer {
+	return atomic.Loadp(addr)
+}
+`
+}
+
+// RuntimeExtraFileContent 返回编译使用的 Go 版本对应的 runtime 扩展文件，版本未知时使用最新的
+func RuntimeExtraFileContent(goVersion string) string {
+	for _, ext := range RuntimeExtensions {
+		if goVersion == "" || GoVersionAtLeast(goVersion, ext.MinGoVersion) {
+			return ext.Content
+		}
+	}
+	return RuntimeExtensions[len(RuntimeExtensions)-1].Content
+}
+
+// GoVersionAtLeast 判断 Go 版本（如 go1.21.3、devel go1.23-abcdef）是否不低于 min，min 为空时总是成立
+func GoVersionAtLeast(version, min string) bool {
+	if min == "" {
+		return true
+	}
+	v, ok := ParseGoVersion(version)
+	if !ok {
+		return false
+	}
+	m, ok := ParseGoVersion(min)
+	if !ok {
+		return false
+	}
+	for i := range v {
+		if v[i] != m[i] {
+			return v[i] > m[i]
+		}
+	}
+	return true
+}
+
+// ParseGoVersion 解析 Go 版本中的 major、minor 与 patch 版本号，忽略 rc、beta 等后缀
+func ParseGoVersion(version string) (v [3]int, ok bool) {
+	i := strings.Index(version, "go1")
+	if i < 0 {
+		return v, false
+	}
+	version = version[i+len("go"):]
+	// Trim what follows the version number: suffixes, devel revision, etc.
+	if end := strings.IndexFunc(version, func(r rune) bool {
+		return r != '.' && (r < '0' || r > '9')
+	}); end >= 0 {
+		version = version[:end]
+	}
+	for i, part := range strings.SplitN(version, ".", 3) {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return v, i > 0
+		}
+		v[i] = n
+	}
+	return v, true
+}
@@ -0,0 +1,188 @@
+package configs
+
+import (
+	"fmt"
+	"go/token"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// SnippetData 为渲染 prolog/epilog 代码片段（text/template）时可使用的 Hook 点信息
+type SnippetData struct {
+	// 函数签名，如 `net/http.(*Client).Do`
+	Symbol string
+	// Hook ID
+	ID string
+	// 接收者及参数，即 prolog 的参数
+	Params []SnippetVar
+	// 以 `, ` 分隔的接收者及参数名
+	ParamNames string
+	// 返回值，即 epilog 的参数
+	Results []SnippetVar
+	// 以 `, ` 分隔的返回值名
+	ResultNames string
+	// 最后一个返回值的类型为 error 时，该返回值的名字
+	ErrorResult string
+	// prolog 函数、prolog 返回的 epilog 函数以及中止错误的变量名
+	PrologVar   string
+	EpilogVar   string
+	AbortErrVar string
+	// 模板参数，见 Code.With
+	With map[string]string
+}
+
+// Template 为具名的代码片段模板，codes 通过 `use: <模板名>` 引用，并通过 `with` 传入参数
+type Template struct {
+	// 模板参数及其默认值，codes 只能传入声明的参数
+	Params map[string]string `yaml:"params,omitempty"`
+	Prolog string            `yaml:"prolog,omitempty"`
+	Epilog string            `yaml:"epilog,omitempty"`
+	// 代码片段引用的包，与使用模板的 codes 中的 imports 合并
+	Imports StringList `yaml:"imports,omitempty"`
+}
+
+// SnippetImport 为代码片段引用的包，配置为 `<包路径>` 或 `<包名> <包路径>`，包名默认为包路径的最后一段；
+// 包名为 `_` 时为空白导入，只用于使该包先于被插桩包初始化
+type SnippetImport struct {
+	Name string
+	Path string
+}
+
+// Major version suffix of the module paths, eg. `/v2`.
+var majorVersionSuffix = regexp.MustCompile(`^v[0-9]+$`)
+
+// ParseSnippetImport parses an import of the code snippets.
+func ParseSnippetImport(spec string) (SnippetImport, error) {
+	fields := strings.Fields(spec)
+	var imp SnippetImport
+	switch len(fields) {
+	case 1:
+		imp.Path = fields[0]
+		elems := strings.Split(imp.Path, "/")
+		imp.Name = elems[len(elems)-1]
+		if len(elems) > 1 && majorVersionSuffix.MatchString(imp.Name) {
+			imp.Name = elems[len(elems)-2]
+		}
+	case 2:
+		imp.Name, imp.Path = fields[0], fields[1]
+	default:
+		return imp, fmt.Errorf("invalid import `%s`: expected `<path>` or `<name> <path>`", spec)
+	}
+	if !token.IsIdentifier(imp.Name) {
+		return imp, fmt.Errorf("invalid import `%s`: `%s` is not a valid package name", spec, imp.Name)
+	}
+	if imp.Path == "" || strings.ContainsAny(imp.Path, `\"'`) || strings.HasPrefix(imp.Path, ".") {
+		return imp, fmt.Errorf("invalid import `%s`: invalid import path", spec)
+	}
+	return imp, nil
+}
+
+// Ident returns the name the package is imported with into the instrumented
+// files, which cannot conflict with the names of the files. Blank imports
+// keep their blank name.
+func (imp SnippetImport) Ident() string {
+	if imp.Name == "_" {
+		return imp.Name
+	}
+	return fmt.Sprintf(SnippetImportIdentFormat, normalizedImportPath.ReplaceAllString(imp.Path, "_"))
+}
+
+var normalizedImportPath = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// resolveTemplates replaces the templates used by the code snippets with
+// their prolog and epilog, and their parameters with the ones of the code
+// snippets and the default ones.
+func (c *Config) resolveTemplates() error {
+	for signatrue, code := range c.Codes {
+		if code.Use == "" {
+			continue
+		}
+		template, ok := c.Templates[code.Use]
+		if !ok {
+			return fmt.Errorf("code snippet of `%s` uses undefined template `%s`", signatrue, code.Use)
+		}
+		if code.Prolog == "" {
+			code.Prolog = template.Prolog
+		}
+		if code.Epilog == "" {
+			code.Epilog = template.Epilog
+		}
+		code.With = templateParams(template, code.With)
+		code.Imports = appendMissing(append(StringList{}, template.Imports...), code.Imports...)
+		c.Codes[signatrue] = code
+	}
+	return nil
+}
+
+// templateParams returns the parameters of the template, with the given
+// values overriding the default ones.
+func templateParams(template Template, with map[string]string) map[string]string {
+	params := make(map[string]string, len(template.Params))
+	for name, value := range template.Params {
+		params[name] = value
+	}
+	for name, value := range with {
+		params[name] = value
+	}
+	return params
+}
+
+// SnippetVar 为函数的参数或返回值，未命名或为 `_` 的参数在插桩时会被命名
+type SnippetVar struct {
+	Name string
+	Type string
+}
+
+// NewSnippetData returns the snippet data of the function, whose parameters
+// and results are given by name and type.
+func NewSnippetData(signatrue, id string, params, results []SnippetVar) *SnippetData {
+	var errorResult string
+	if len(results) > 0 && results[len(results)-1].Type == "error" {
+		errorResult = results[len(results)-1].Name
+	}
+	return &SnippetData{
+		ErrorResult: errorResult,
+		Symbol:      signatrue,
+		ID:          id,
+		Params:      params,
+		ParamNames:  snippetVarNames(params),
+		Results:     results,
+		ResultNames: snippetVarNames(results),
+		PrologVar:   PrologVarIdent,
+		EpilogVar:   EpilogVarIdent,
+		AbortErrVar: PrologAbortErrorVarIdent,
+	}
+}
+
+func snippetVarNames(vars []SnippetVar) string {
+	names := make([]string, len(vars))
+	for i, v := range vars {
+		names[i] = v.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// ParseSnippet parses the code snippet as a template named after the field
+// of the code, ie. `prolog` or `epilog`.
+func ParseSnippet(name, snippet string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=error").Parse(snippet)
+}
+
+// RenderSnippet 使用 Hook 点信息渲染代码片段
+func RenderSnippet(name, snippet string, data *SnippetData) (string, error) {
+	tmpl, err := ParseSnippet(name, snippet)
+	if err != nil {
+		return "", err
+	}
+	var code strings.Builder
+	if err := tmpl.Execute(&code, data); err != nil {
+		return "", err
+	}
+	return code.String(), nil
+}
+
+// sampleSnippetData is used to check the snippets of the configuration.
+var sampleSnippetData = NewSnippetData("example.com/pkg.(*T).F", "example_com_pkg_T_F",
+	[]SnippetVar{{Name: "t", Type: "*T"}, {Name: "_param1", Type: "string"}},
+	[]SnippetVar{{Name: "_result0", Type: "error"}})
@@ -0,0 +1,446 @@
+package configs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Diagnostic 为配置文件检查发现的问题
+type Diagnostic struct {
+	Filename string
+	// Line of the configuration file, 0 when unknown.
+	Line    int
+	Message string
+	// Warnings, such as unused templates, don't invalidate the config.
+	Warning bool
+}
+
+// ValidationError lists the problems found in the configuration files.
+type ValidationError struct {
+	Diagnostics []Diagnostic
+}
+
+func (e *ValidationError) Error() string {
+	var msg strings.Builder
+	msg.WriteString("invalid config:")
+	for _, d := range e.Diagnostics {
+		msg.WriteString("\n\t")
+		msg.WriteString(d.String())
+	}
+	return msg.String()
+}
+
+func (d Diagnostic) String() string {
+	msg := d.Message
+	if d.Warning {
+		msg = "warning: " + msg
+	}
+	if d.Line == 0 {
+		return fmt.Sprintf("%s: %s", d.Filename, msg)
+	}
+	return fmt.Sprintf("%s:%d: %s", d.Filename, d.Line, msg)
+}
+
+// splitWarnings separates the warnings from the errors of the diagnostics.
+func splitWarnings(diags []Diagnostic) (errs, warnings []Diagnostic) {
+	for _, d := range diags {
+		if d.Warning {
+			warnings = append(warnings, d)
+		} else {
+			errs = append(errs, d)
+		}
+	}
+	return errs, warnings
+}
+
+// Line numbers of the yaml.v3 error messages.
+var yamlErrorLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): `)
+
+func yamlDiagnostic(filename, msg string) Diagnostic {
+	if m := yamlErrorLine.FindStringSubmatch(msg); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return Diagnostic{Filename: filename, Line: line, Message: msg[len(m[0]):]}
+	}
+	return Diagnostic{Filename: filename, Message: strings.TrimPrefix(msg, "yaml: ")}
+}
+
+// ValidateConfigFiles 检查配置文件及其 include 的文件：YAML 语法与字段、Hook 点与包名是否一致、代码片段能否解析以及是否对应已声明的 Hook 点。
+// configFile 可以为以 os.PathListSeparator 分隔的多个文件，返回不影响构建的警告（如未被使用的模板），有问题时返回 *ValidationError
+func ValidateConfigFiles(configFile string) (warnings []Diagnostic, err error) {
+	layers, err := loadConfigLayers(SplitConfigFiles(configFile))
+	if err != nil {
+		return nil, err
+	}
+	errs, warnings := splitWarnings(validateLayers(layers))
+	if len(errs) > 0 {
+		return warnings, &ValidationError{Diagnostics: errs}
+	}
+	return warnings, nil
+}
+
+// validateLayers returns the problems of the config files, by file in the
+// merge order and by line.
+func validateLayers(layers []configLayer) []Diagnostic {
+	v := &validator{}
+	order := make(map[string]int)
+	for i, layer := range layers {
+		order[layer.filename] = i
+		v.validateFile(layer)
+	}
+	v.validateCodes()
+	sort.SliceStable(v.diags, func(i, j int) bool {
+		if a, b := order[v.diags[i].Filename], order[v.diags[j].Filename]; a != b {
+			return a < b
+		}
+		return v.diags[i].Line < v.diags[j].Line
+	})
+	return v.diags
+}
+
+func (v *validator) validateFile(layer configLayer) {
+	v.filename = layer.filename
+	var root yaml.Node
+	if err := yaml.Unmarshal(layer.data, &root); err != nil {
+		v.diags = append(v.diags, yamlDiagnostic(v.filename, err.Error()))
+		return
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(layer.data))
+	decoder.KnownFields(true)
+	var config Config
+	if err := decoder.Decode(&config); err != nil && err != io.EOF {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			v.diags = append(v.diags, yamlDiagnostic(v.filename, err.Error()))
+			return
+		}
+		for _, msg := range typeErr.Errors {
+			v.diags = append(v.diags, yamlDiagnostic(v.filename, msg))
+		}
+	}
+
+	if len(root.Content) > 0 {
+		v.validateTemplates(root.Content[0])
+		v.validateSection(root.Content[0])
+		if callsites := mappingValue(root.Content[0], "callsites"); callsites != nil {
+			for i := 0; i+1 < len(callsites.Content); i += 2 {
+				v.validateCallsites(callsites.Content[i], callsites.Content[i+1])
+			}
+		}
+		if interfaces := mappingValue(root.Content[0], "interfaces"); interfaces != nil {
+			for i := 0; i+1 < len(interfaces.Content); i += 2 {
+				v.validateInterfaces(interfaces.Content[i], interfaces.Content[i+1])
+			}
+		}
+		if sections := mappingValue(root.Content[0], "sections"); sections != nil {
+			for _, section := range sections.Content {
+				v.validateSection(section)
+			}
+		}
+	}
+}
+
+type validator struct {
+	diags []Diagnostic
+	// The file being validated.
+	filename string
+	// Declared hookpoints, by package or package wildcard.
+	hookpoints map[string][]string
+	// Declared callsite signatures.
+	callsites map[string]bool
+	// Declared interface method signatures.
+	interfaces map[string]bool
+	// Code snippets, checked once all the files are validated.
+	codes []codeEntry
+	// Code snippet templates, by name.
+	templates map[string]*templateEntry
+}
+
+type codeEntry struct {
+	filename string
+	key      *yaml.Node
+	code     *yaml.Node
+}
+
+type templateEntry struct {
+	filename string
+	key      *yaml.Node
+	params   map[string]string
+	used     bool
+}
+
+func (v *validator) errorf(node *yaml.Node, format string, args ...interface{}) {
+	v.diags = append(v.diags, Diagnostic{Filename: v.filename, Line: node.Line, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) warnf(node *yaml.Node, format string, args ...interface{}) {
+	v.diags = append(v.diags, Diagnostic{Filename: v.filename, Line: node.Line, Message: fmt.Sprintf(format, args...), Warning: true})
+}
+
+// validateSection validates the hookpoints and the code snippets of the
+// mapping node, the config itself or one of its sections.
+func (v *validator) validateSection(node *yaml.Node) {
+	if hookpoints := mappingValue(node, "hookpoints"); hookpoints != nil {
+		for i := 0; i+1 < len(hookpoints.Content); i += 2 {
+			v.validateHookpoints(hookpoints.Content[i], hookpoints.Content[i+1])
+		}
+	}
+	if codes := mappingValue(node, "codes"); codes != nil {
+		for i := 0; i+1 < len(codes.Content); i += 2 {
+			v.codes = append(v.codes, codeEntry{filename: v.filename, key: codes.Content[i], code: codes.Content[i+1]})
+		}
+	}
+}
+
+// validateTemplates validates the code snippet templates, rendered with their
+// default parameters. A template overrides the one of the same name of the
+// previous files.
+func (v *validator) validateTemplates(node *yaml.Node) {
+	templates := mappingValue(node, "templates")
+	if templates == nil {
+		return
+	}
+	if v.templates == nil {
+		v.templates = make(map[string]*templateEntry)
+	}
+	for i := 0; i+1 < len(templates.Content); i += 2 {
+		key, value := templates.Content[i], templates.Content[i+1]
+		var template Template
+		// Type errors are reported by the schema check.
+		_ = value.Decode(&template)
+		v.templates[key.Value] = &templateEntry{filename: v.filename, key: key, params: template.Params}
+		v.validateSnippets(fmt.Sprintf("template `%s`", key.Value), value, template.Params)
+	}
+}
+
+func (v *validator) validateSnippets(owner string, node *yaml.Node, with map[string]string) {
+	if imports := mappingValue(node, "imports"); imports != nil {
+		specs := imports.Content
+		if imports.Kind == yaml.ScalarNode {
+			specs = []*yaml.Node{imports}
+		}
+		for _, spec := range specs {
+			if _, err := ParseSnippetImport(spec.Value); err != nil {
+				v.errorf(spec, "imports of %s: %v", owner, err)
+			}
+		}
+	}
+	for _, field := range []string{"prolog", "epilog"} {
+		if snippet := mappingValue(node, field); snippet != nil {
+			v.validateSnippet(owner, field, snippet, with)
+		}
+	}
+}
+
+func (v *validator) validateHookpoints(pkgNode, list *yaml.Node) {
+	pkgPath := pkgNode.Value
+	if v.hookpoints == nil {
+		v.hookpoints = make(map[string][]string)
+	}
+	wildcard := IsPackageWildcard(pkgPath)
+	for _, node := range list.Content {
+		hookpoint := node.Value
+		v.hookpoints[pkgPath] = append(v.hookpoints[pkgPath], hookpoint)
+		if wildcard {
+			if name := strings.TrimPrefix(hookpoint, RegexpHookpointPrefix); strings.Contains(name, "/") {
+				v.errorf(node, "hookpoint `%s` of package wildcard `%s` must be relative to the matched packages, eg. `Func` or `(*Type).Method`", hookpoint, pkgPath)
+				continue
+			}
+			if IsHookpointPattern(hookpoint) {
+				if _, err := CompileHookpointPattern(newPackageWildcard(pkgPath, nil).Expand(pkgPath, hookpoint)); err != nil {
+					v.errorf(node, "invalid hookpoint pattern `%s`: %v", hookpoint, errors.Unwrap(err))
+				}
+			}
+			continue
+		} else if !strings.HasPrefix(hookpoint, RegexpHookpointPrefix) && !strings.HasPrefix(hookpoint, pkgPath+".") {
+			v.errorf(node, "hookpoint `%s` does not belong to package `%s`", hookpoint, pkgPath)
+			continue
+		}
+		if IsHookpointPattern(hookpoint) {
+			if _, err := CompileHookpointPattern(hookpoint); err != nil {
+				v.errorf(node, "%v", err)
+			}
+		}
+	}
+}
+
+// Line of the code snippet in the code template.
+const codeTemplateSnippetLine = 4
+
+// Line numbers of the text/template error messages.
+var templateErrorLine = regexp.MustCompile(`^template: \w+:(\d+):(?:\d+:)? `)
+
+// validateSnippet renders the code snippet with sample data and parses it as
+// it is inserted into the instrumented functions.
+func (v *validator) validateSnippet(owner, field string, node *yaml.Node, with map[string]string) {
+	// Line of the snippet's first line in the config.
+	firstLine := node.Line
+	if node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		firstLine++
+	}
+	lines := strings.Count(strings.TrimRight(node.Value, "\n"), "\n")
+	report := func(line int, msg string) {
+		// The errors at the end of the snippet are reported on its last line.
+		if line > lines {
+			line = lines
+		}
+		v.diags = append(v.diags, Diagnostic{
+			Filename: v.filename,
+			Line:     firstLine + line,
+			Message:  fmt.Sprintf("%s of %s: %s", field, owner, msg),
+		})
+	}
+
+	data := *sampleSnippetData
+	data.With = with
+	code, err := RenderSnippet(field, node.Value, &data)
+	if err != nil {
+		msg := err.Error()
+		if m := templateErrorLine.FindStringSubmatch(msg); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			report(line-1, msg[len(m[0]):])
+			return
+		}
+		report(0, msg)
+		return
+	}
+
+	_, err = parser.ParseFile(token.NewFileSet(), "", fmt.Sprintf(CodeTemplate, code), 0)
+	if err == nil {
+		return
+	}
+	var list scanner.ErrorList
+	if !errors.As(err, &list) || len(list) == 0 {
+		report(0, err.Error())
+		return
+	}
+	// The following errors mostly result from the first one.
+	report(list[0].Pos.Line-codeTemplateSnippetLine, list[0].Msg)
+}
+
+// validateCodes checks every code snippet is used by a declared hookpoint,
+// uses a defined template with its parameters, and every template is used.
+func (v *validator) validateCodes() {
+	for _, entry := range v.codes {
+		v.filename = entry.filename
+		signatrue := entry.key.Value
+		if !v.declared(signatrue) {
+			v.errorf(entry.key, "code snippet of `%s` matches no declared hookpoint, callsite or interface method", signatrue)
+		}
+		var code Code
+		_ = entry.code.Decode(&code)
+		if kind := mappingValue(entry.code, "kind"); kind != nil {
+			if _, err := LookupHookKind(code.Kind); err != nil {
+				v.errorf(kind, "code snippet of `%s`: %v", signatrue, err)
+			}
+			if code.Prolog != "" || code.Epilog != "" || code.Use != "" {
+				v.errorf(kind, "code snippet of `%s`: kind cannot be combined with prolog, epilog or use", signatrue)
+			}
+		}
+		var template Template
+		if use := mappingValue(entry.code, "use"); use != nil {
+			t, ok := v.templates[code.Use]
+			if !ok {
+				v.errorf(use, "code snippet of `%s` uses undefined template `%s`", signatrue, code.Use)
+				continue
+			}
+			t.used = true
+			template.Params = t.params
+			if with := mappingValue(entry.code, "with"); with != nil {
+				for i := 0; i+1 < len(with.Content); i += 2 {
+					if _, ok := t.params[with.Content[i].Value]; !ok {
+						v.errorf(with.Content[i], "template `%s` has no parameter `%s`", code.Use, with.Content[i].Value)
+					}
+				}
+			}
+		}
+		v.validateSnippets(fmt.Sprintf("`%s`", signatrue), entry.code, templateParams(template, code.With))
+	}
+	// Shared baselines can define templates that some configs don't use.
+	for name, t := range v.templates {
+		if !t.used {
+			v.filename = t.filename
+			v.warnf(t.key, "template `%s` is never used", name)
+		}
+	}
+}
+
+func (v *validator) declared(signatrue string) bool {
+	if v.callsites[signatrue] || v.interfaces[signatrue] {
+		return true
+	}
+	for key, hookpoints := range v.hookpoints {
+		if !IsPackageWildcard(key) {
+			if strings.HasPrefix(signatrue, key+".") && hookpointsMatch(hookpoints, signatrue) {
+				return true
+			}
+			continue
+		}
+		// Package paths can have dots, eg. `gopkg.in/yaml.v3`: try every
+		// prefix of the signature ending before a dot.
+		w := newPackageWildcard(key, nil)
+		for i := strings.IndexByte(signatrue, '.'); i >= 0; i = nextIndexByte(signatrue, '.', i) {
+			pkgPath := signatrue[:i]
+			if !w.Match(pkgPath) {
+				continue
+			}
+			expanded := make([]string, 0, len(hookpoints))
+			for _, hookpoint := range hookpoints {
+				expanded = append(expanded, w.Expand(pkgPath, hookpoint))
+			}
+			if hookpointsMatch(expanded, signatrue) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hookpointsMatch returns true when one of the hookpoints is the signature or
+// a pattern matching it.
+func hookpointsMatch(hookpoints []string, signatrue string) bool {
+	for _, hookpoint := range hookpoints {
+		if hookpoint == signatrue {
+			return true
+		}
+		if !IsHookpointPattern(hookpoint) {
+			continue
+		}
+		if pattern, err := CompileHookpointPattern(hookpoint); err == nil && pattern.Match(signatrue) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextIndexByte returns the index of the next c in s after i, -1 if none.
+func nextIndexByte(s string, c byte, i int) int {
+	if j := strings.IndexByte(s[i+1:], c); j >= 0 {
+		return i + 1 + j
+	}
+	return -1
+}
+
+// mappingValue returns the value node of the key of the mapping node.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package configs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateCodesDeclared(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		config   string
+		declared bool
+	}{
+		{
+			name:     "function",
+			config:   "hookpoints: {os: [os.Open]}\ncodes: {os.Open: {prolog: ''}}",
+			declared: true,
+		},
+		{
+			name:     "method",
+			config:   "hookpoints: {net/http: [net/http.(*Client).Do]}\ncodes: {net/http.(*Client).Do: {prolog: ''}}",
+			declared: true,
+		},
+		{
+			name:     "dotted last path segment",
+			config:   "hookpoints: {gopkg.in/yaml.v3: [gopkg.in/yaml.v3.Unmarshal]}\ncodes: {gopkg.in/yaml.v3.Unmarshal: {prolog: ''}}",
+			declared: true,
+		},
+		{
+			name:     "pattern of a dotted package",
+			config:   "hookpoints: {go.uber.org/zap: ['go.uber.org/zap.(*Logger).*']}\ncodes: {go.uber.org/zap.(*Logger).Info: {prolog: ''}}",
+			declared: true,
+		},
+		{
+			name:     "package wildcard",
+			config:   "hookpoints: {gopkg.in/...: [Unmarshal]}\ncodes: {gopkg.in/yaml.v3.Unmarshal: {prolog: ''}}",
+			declared: true,
+		},
+		{
+			name:     "package prefix",
+			config:   "hookpoints: {gopkg.in/yaml: [gopkg.in/yaml.v3]}\ncodes: {gopkg.in/yaml.v3.Unmarshal: {prolog: ''}}",
+			declared: false,
+		},
+		{
+			name:     "other package",
+			config:   "hookpoints: {os: [os.Open]}\ncodes: {io.Copy: {prolog: ''}}",
+			declared: false,
+		},
+		{
+			name:     "package wildcard not matching",
+			config:   "hookpoints: {gopkg.in/...: [Marshal]}\ncodes: {gopkg.in/yaml.v3.Unmarshal: {prolog: ''}}",
+			declared: false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(config, []byte(tc.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := ValidateConfigFiles(config)
+			if tc.declared && err != nil {
+				t.Errorf("got error %v", err)
+			}
+			if !tc.declared && (err == nil || !strings.Contains(err.Error(), "matches no declared hookpoint")) {
+				t.Errorf("got error %v, want an undeclared code snippet", err)
+			}
+		})
+	}
+}
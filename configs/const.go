@@ -2,3 +2,15 @@ package configs
 
 const TagCustomGoBin = "CUSTOMGOBIN"
 const TagCustomConfig = "CUSTOMCONFIG"
+
+// 自动查找的配置文件名，从工作目录向上查找直到模块根目录
+const DefaultConfigFileName = ".autobuild.yaml"
+
+// 通过 GOFLAGS 直接使用 -toolexec=autobuild 时，转发给 toolexec 的参数
+const TagCustomToolexecFlags = "CUSTOMTOOLEXECFLAGS"
+
+// autobuild build 转发给 toolexec 的 go build 参数，用于构建代码片段导入的包
+const TagCustomGoBuildFlags = "CUSTOMGOBUILDFLAGS"
+
+// 构建代码片段导入的包时外层构建的工作目录，这些包的 Hook 列表与报告写入其中
+const TagCustomWorkDir = "CUSTOMWORKDIR"
@@ -5,6 +5,10 @@ import (
 	"reflect"
 )
 
+// DoHook attaches the prolog `replacement` to the hook of function `funcSym`.
+// The package init functions share the symbol `<package>.init`: their prolog
+// must be attached by a package initialized before, eg. imported by their
+// code snippets with a blank import.
 func DoHook(funcSym string, replacement interface{}) error {
 	hookPoint, err := Find(funcSym)
 	if err != nil {
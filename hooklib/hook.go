@@ -19,12 +19,19 @@ type (
 	InstrumentationDescriptorType = struct {
 		Version   string
 		HookTable HookTableType
+		// Symbols of the methods instrumented for each interface method,
+		// sorted by interface method.
+		Interfaces []InterfaceImplementationsType
 	}
 	HookTableType          = []HookDescriptorFuncType
 	HookDescriptorFuncType = func(*HookDescriptorType)
 	HookDescriptorType     = struct {
 		Func, PrologVar interface{}
 	}
+	InterfaceImplementationsType = struct {
+		Method          string
+		Implementations []string
+	}
 )
 
 //go:linkname _instrumentation_descriptor _instrumentation_descriptor
@@ -42,9 +49,10 @@ type Hook struct {
 	symbol string
 	// Prolog function type expected by this hook.
 	prologFuncType reflect.Type
-	// Pointer to the prolog pointer. The value has type **prologFuncType, which
-	// is checked at hook creation.
-	prologVarAddr *unsafe.Pointer
+	// Pointers to the prolog pointers. The values have type **prologFuncType,
+	// which is checked at hook creation. Functions instrumented at their
+	// callsites have one prolog variable per calling package.
+	prologVarAddrs []*unsafe.Pointer
 }
 
 func (h *Hook) GetPrologFuncType() reflect.Type {
@@ -118,6 +126,31 @@ func Find(symbol string) (*Hook, error) {
 	return index.find(symbol)
 }
 
+// FindImplementations returns the hooks of the methods instrumented for the
+// given interface method hookpoint, eg. `io.Reader.Read`. Their prolog types
+// depend on their receiver types.
+func FindImplementations(method string) ([]*Hook, error) {
+	if _instrumentation_descriptor == nil {
+		return nil, fmt.Errorf("_instrumentation_descriptor is empty")
+	}
+	interfaces := _instrumentation_descriptor.Interfaces
+	i := sort.Search(len(interfaces), func(i int) bool { return interfaces[i].Method >= method })
+	if i == len(interfaces) || interfaces[i].Method != method {
+		return nil, nil
+	}
+	var hooks []*Hook
+	for _, symbol := range interfaces[i].Implementations {
+		hook, err := Find(symbol)
+		if err != nil {
+			return nil, err
+		}
+		if hook != nil {
+			hooks = append(hooks, hook)
+		}
+	}
+	return hooks, nil
+}
+
 // Try to find the `symbol` in the index first, otherwise try to load it from
 // the hook table.
 func (t symbolIndexType) find(symbol string) (*Hook, error) {
@@ -137,7 +170,7 @@ func hookTableLookup(table HookTableType, symbol string, index symbolIndexType)
 	id := normalizedHookID(symbol)
 	// The API of sort.Search doesn't allow to abort, so we panic instead,
 	// caught by sqsafe.Call.
-	sort.Search(len(table), func(i int) bool {
+	first := sort.Search(len(table), func(i int) bool {
 		entry := table[i]
 		var descriptor HookDescriptorType
 		entry(&descriptor)
@@ -152,38 +185,67 @@ func hookTableLookup(table HookTableType, symbol string, index symbolIndexType)
 		}
 		return cmp >= 0
 	})
+	if found == nil {
+		return nil, nil
+	}
+	// The callsite hooks of the symbol follow its first hook in the table.
+	for i := first + 1; i < len(table) && err == nil; i++ {
+		var descriptor HookDescriptorType
+		table[i](&descriptor)
+		if next, symbolErr := descriptorSymbol(descriptor.Func); symbolErr != nil || normalizedHookID(next) != id {
+			// Another symbol
+			break
+		}
+		_, err = index.add(descriptor.Func, descriptor.PrologVar)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("hook table lookup of symbol `%s`,err:%s", symbol, err)
+		return nil, fmt.Errorf("hook table lookup of symbol `%s`: %w", symbol, err)
 	}
 	return found, nil
 }
 
 func normalizedHookID(symbol string) string {
+	// The type parameters of generic functions, eg. `pkg.Map[...]`
+	symbol = strings.ReplaceAll(symbol, "[...]", "")
 	id := regexp.MustCompile(`[ *()]`).ReplaceAllString(symbol, "")
 	return regexp.MustCompile(`[/.\-@]`).ReplaceAllString(id, "_")
 }
 
-// add creates the hook object for function `fn`, adds it to the find map and
-// returns it. It returns an error if it is not possible.
-func (t symbolIndexType) add(fn, prologVar interface{}) (h *Hook, err error) {
-	// Check fn is a non-nil function value
+// descriptorSymbol returns the symbol of the function of a hook descriptor.
+func descriptorSymbol(fn interface{}) (string, error) {
+	// Check fn is a non-nil function value, or the symbol name of a generic
+	// function which has no function value.
 	if fn == nil {
-		return nil, errors.New("unexpected function argument value `nil`")
-	}
-	fnValue := reflect.ValueOf(fn)
-	fnType := fnValue.Type()
-	if fnType.Kind() != reflect.Func {
-		return nil, errors.Errorf("unexpected function argument type: expecting a function value but got `%T`", fn)
+		return "", errors.New("unexpected function argument value `nil`")
 	}
+	symbol, ok := fn.(string)
+	if !ok {
+		fnValue := reflect.ValueOf(fn)
+		if fnValue.Kind() != reflect.Func {
+			return "", errors.Errorf("unexpected function argument type: expecting a function value but got `%T`", fn)
+		}
 
-	// Get the symbol name
-	symbol := runtime.FuncForPC(fnValue.Pointer()).Name()
-	if symbol == "" {
-		return nil, errors.Errorf("could not read the symbol name of function `%T`", fn)
+		// Get the symbol name
+		symbol = runtime.FuncForPC(fnValue.Pointer()).Name()
+		if symbol == "" {
+			return "", errors.Errorf("could not read the symbol name of function `%T`", fn)
+		}
 	}
-
 	// Unvendor it so that it is not prefixed by `<app>/vendor/`
-	symbol = utils.Unvendor(symbol)
+	return utils.Unvendor(symbol), nil
+}
+
+// add creates the hook object for function `fn`, adds it to the find map and
+// returns it. It returns an error if it is not possible.
+func (t symbolIndexType) add(fn, prologVar interface{}) (h *Hook, err error) {
+	symbol, err := descriptorSymbol(fn)
+	if err != nil {
+		return nil, err
+	}
+	var fnType reflect.Type
+	if _, ok := fn.(string); !ok {
+		fnType = reflect.TypeOf(fn)
+	}
 
 	// Use the symbol name for better error messages
 	defer func() {
@@ -192,30 +254,40 @@ func (t symbolIndexType) add(fn, prologVar interface{}) (h *Hook, err error) {
 		}
 	}()
 
-	// The hook may have been already added by a previous lookup
-	if hook, exists := t[symbol]; exists {
-		return hook, nil
-	}
-
 	// Check the prolog variable is compatible with the function
 	if prologVar == nil {
 		return nil, errors.New("unexpected prolog variable argument value `nil`")
 	}
 	prologVarValue := reflect.ValueOf(prologVar)
 	prologFuncType := prologVarValue.Type()
+	prologVarAddr := (*unsafe.Pointer)(unsafe.Pointer(prologVarValue.Pointer()))
+
+	// The hook may have been already added by a previous lookup, or by
+	// another callsite of the function.
+	if hook, exists := t[symbol]; exists {
+		for _, addr := range hook.prologVarAddrs {
+			if addr == prologVarAddr {
+				return hook, nil
+			}
+		}
+		if prologFuncType.Kind() != reflect.Ptr || prologFuncType.Elem().Kind() != reflect.Ptr || prologFuncType.Elem().Elem() != hook.prologFuncType {
+			return nil, errors.Errorf("unexpected prolog variable type `%s` instead of `**%s`", prologFuncType, hook.prologFuncType)
+		}
+		hook.prologVarAddrs = append(hook.prologVarAddrs, prologVarAddr)
+		return hook, nil
+	}
 
 	if err := validatePrologVar(fnType, prologFuncType); err != nil {
 		return nil, errors.Wrap(err, "prolog variable validation")
 	}
 
 	prologFuncType = prologFuncType.Elem().Elem()
-	prologVarAddr := (*unsafe.Pointer)(unsafe.Pointer(prologVarValue.Pointer()))
 
 	// Create the hook, store it in the map and return it.
 	hook := &Hook{
 		symbol:         symbol,
 		prologFuncType: prologFuncType,
-		prologVarAddr:  prologVarAddr,
+		prologVarAddrs: []*unsafe.Pointer{prologVarAddr},
 	}
 	t[symbol] = hook
 	return hook, nil
@@ -229,10 +301,11 @@ func (h *Hook) Attach(prolog PrologCallback) (err error) {
 			err = errors.New(fmt.Sprintf("set failed: %v", r))
 		}
 	}()
-	addr := h.prologVarAddr
 	if prolog == nil {
 		// Disable
-		atomic.StorePointer(addr, nil)
+		for _, addr := range h.prologVarAddrs {
+			atomic.StorePointer(addr, nil)
+		}
 		return nil
 	}
 	// 类型检查
@@ -246,7 +319,9 @@ func (h *Hook) Attach(prolog PrologCallback) (err error) {
 	// *ptr = prolog
 	ptr.Elem().Set(prologValue)
 	// Atomically store it: *addr = ptr
-	atomic.StorePointer(addr, unsafe.Pointer(ptr.Pointer()))
+	for _, addr := range h.prologVarAddrs {
+		atomic.StorePointer(addr, unsafe.Pointer(ptr.Pointer()))
+	}
 	return nil
 }
 
@@ -258,6 +333,10 @@ func (h *Hook) Attach(prolog PrologCallback) (err error) {
 // The expected prolog variable to use is:
 //
 //	var prologVarForF **prolog
+//
+// The function type is nil for generic functions, whose prolog parameters
+// using type parameters have type `interface{}`: only the prolog variable
+// shape is then validated.
 func validatePrologVar(fnType, prologVarType reflect.Type) error {
 	// Check the prolog variable type is a `**func`.
 	if prologVarType.Kind() != reflect.Ptr ||
@@ -265,6 +344,12 @@ func validatePrologVar(fnType, prologVarType reflect.Type) error {
 		prologVarType.Elem().Elem().Kind() != reflect.Func {
 		return errors.Errorf("prolog variable type is not a `**func` but `%s`", prologVarType)
 	}
+	if fnType == nil {
+		if prologType := prologVarType.Elem().Elem(); prologType.NumOut() != 2 || prologType.Out(0).Kind() != reflect.Func {
+			return errors.Errorf("unexpected prolog function type `%s`", prologType)
+		}
+		return nil
+	}
 	if err := validateProlog(fnType, prologVarType.Elem().Elem()); err != nil {
 		return errors.Wrap(err, "prolog function type validation")
 	}
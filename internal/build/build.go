@@ -1,8 +1,11 @@
 package build
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 
 	"github.com/ListenOcean/goHookTool/configs"
 	"github.com/ListenOcean/goHookTool/internal/build/log"
@@ -16,20 +19,82 @@ var (
 	AutobuildPath string
 )
 
+// Options of the build command. Everything after `--` is given to go build.
+type buildFlagSet struct {
+	Config           string
+	Go               string
+	Full             bool
+	Verbose          bool
+	Strict           bool
+	KeepInstrumented string
+	LineDirectives   string
+	Report           string
+}
+
+var Flags buildFlagSet
+
 // buildCmd represents the build command
 var BuildCmd = &cobra.Command{
-	Use:                "build",
-	Short:              "Build a go application with hook.",
-	RunE:               BuildEntry,
-	DisableFlagParsing: true,
+	Use:   "build [flags] -- [go build flags] [packages]",
+	Short: "Build a go application with hook.",
+	RunE:  BuildEntry,
+}
+
+func init() {
+	AddToolexecFlags(BuildCmd)
+}
+
+// AddToolexecFlags 添加转发给 toolexec 的参数
+func AddToolexecFlags(cmd *cobra.Command) {
+	AddCommonFlags(cmd)
+	flags := cmd.Flags()
+	flags.BoolVar(&Flags.Full, "full", false, "instrument every package, even without hookpoints")
+	flags.BoolVarP(&Flags.Verbose, "verbose", "v", false, "show the instrumentation logs")
+	flags.BoolVar(&Flags.Strict, "strict", false, "fail the build when configured hookpoints are not instrumented")
+	flags.StringVar(&Flags.KeepInstrumented, "keep-instrumented", "", "keep the instrumented files into this directory")
+	flags.StringVar(&Flags.LineDirectives, "line-directives", "", "line directives of the instrumented files: original or instrumented")
+	flags.StringVar(&Flags.Report, "report", "", "write the JSON instrumentation report to this path")
+}
+
+// AddCommonFlags 添加所有命令共用的参数（配置文件与 go 可执行文件）
+func AddCommonFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.StringVar(&Flags.Config, "config", "", "hook configuration files, separated by the OS path list separator (default $"+configs.TagCustomConfig+", or "+configs.DefaultConfigFileName+" up to the module root)")
+	flags.StringVar(&Flags.Go, "go", "", "go executable (default $"+configs.TagCustomGoBin+", or go in PATH)")
 }
 
 func BuildEntry(cmd *cobra.Command, args []string) (err error) {
-	if WorkDir, err = os.Getwd(); err != nil {
+	// The arguments are valid: errors from now on are not usage errors.
+	cmd.SilenceUsage = true
+	if err = InitPaths(); err != nil {
+		return
+	}
+	// Report the config problems once, instead of from every compile.
+	configFile, err := ConfigPath()
+	if err != nil {
 		return
 	}
+	if configFile != "" {
+		var warnings []configs.Diagnostic
+		warnings, err = configs.ValidateConfigFiles(configFile)
+		for _, d := range warnings {
+			fmt.Fprintln(os.Stderr, d.String())
+		}
+		if err != nil {
+			return
+		}
+	}
 
-	if GoPath, err = exec.LookPath("go"); err != nil {
+	if err = ForwardBuild(args); err != nil {
+		log.Debug("ForwardBuild Fail.", log.String("err", err.Error()))
+		return
+	}
+	return nil
+}
+
+// InitPaths 初始化工作目录、go 可执行文件以及 autobuild 自身的路径
+func InitPaths() (err error) {
+	if WorkDir, err = os.Getwd(); err != nil {
 		return
 	}
 
@@ -37,14 +102,66 @@ func BuildEntry(cmd *cobra.Command, args []string) (err error) {
 		return
 	}
 
-	customGoBin := os.Getenv(configs.TagCustomGoBin)
-	if customGoBin != "" {
+	switch customGoBin := os.Getenv(configs.TagCustomGoBin); {
+	case Flags.Go != "":
+		GoPath = Flags.Go
+	case customGoBin != "":
 		GoPath = customGoBin
+	default:
+		if GoPath, err = exec.LookPath("go"); err != nil {
+			return
+		}
 	}
+	return nil
+}
 
-	if err = ForwardBuild(); err != nil {
-		log.Error("ForwardBuild Fail.", log.String("err", err.Error()))
-		return
+// ConfigPath 返回配置文件的绝对路径，依次为 --config、CUSTOMCONFIG 以及自动查找的 .autobuild.yaml，均未找到时为空。
+// 多个配置文件以 os.PathListSeparator 分隔
+func ConfigPath() (string, error) {
+	configFile := Flags.Config
+	if configFile == "" {
+		configFile = os.Getenv(configs.TagCustomConfig)
 	}
-	return nil
+	if configFile == "" {
+		return findConfigFile(WorkDir)
+	}
+	// toolexec is run from the package directories
+	files := configs.SplitConfigFiles(configFile)
+	for i, file := range files {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return "", err
+		}
+		files[i] = abs
+	}
+	return strings.Join(files, string(os.PathListSeparator)), nil
+}
+
+// findConfigFile searches the default config file from dir up to the root of
+// its module or workspace, ie. the first directory having a go.mod or go.work
+// file.
+func findConfigFile(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		configFile := filepath.Join(dir, configs.DefaultConfigFileName)
+		if fileExists(configFile) {
+			return configFile, nil
+		}
+		if fileExists(filepath.Join(dir, "go.mod")) || fileExists(filepath.Join(dir, "go.work")) {
+			return "", nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
 }
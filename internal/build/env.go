@@ -0,0 +1,104 @@
+package build
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ListenOcean/goHookTool/configs"
+	"github.com/ListenOcean/goHookTool/utils"
+
+	"github.com/spf13/cobra"
+)
+
+var envFlags struct {
+	Tags   string
+	Format string
+}
+
+var EnvCmd = &cobra.Command{
+	Use:   "env [flags]",
+	Short: "Print the go settings building with hook from any go command.",
+	Long: `Print the go settings building with hook from any go command, such as go
+test or go generate run by Makefiles or IDEs.
+
+GOFLAGS cannot hold -toolexec values with spaces, so it is set to autobuild
+alone, which reads its toolexec options from $` + configs.TagCustomToolexecFlags + `.
+The toolexec flag of the go command line is printed with --format=flag.`,
+	Args: cobra.NoArgs,
+	RunE: EnvEntry,
+}
+
+func init() {
+	AddToolexecFlags(EnvCmd)
+	EnvCmd.Flags().StringVar(&envFlags.Tags, "tags", "", "comma-separated build tags, for the config section conditions (default from $GOFLAGS)")
+	EnvCmd.Flags().StringVar(&envFlags.Format, "format", "shell", "output format: shell, goenv (go env -w arguments) or flag (go command line flags)")
+}
+
+func EnvEntry(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+	if err := InitPaths(); err != nil {
+		return err
+	}
+	tags := BuildTags(nil)
+	if envFlags.Tags != "" {
+		tags = splitTags(envFlags.Tags)
+	}
+	return printEnv(os.Stdout, envFlags.Format, tags)
+}
+
+func printEnv(w io.Writer, format string, tags []string) error {
+	if format == "flag" {
+		toolexecFlag, err := ToolexecFlag(tags)
+		if err != nil {
+			return err
+		}
+		// -a: every package must be compiled to get the complete hook table.
+		_, err = fmt.Fprintf(w, "-a %s\n", shellQuote(toolexecFlag))
+		return err
+	}
+
+	env, err := ToolexecEnv(tags)
+	if err != nil {
+		return err
+	}
+	for _, kv := range env {
+		switch format {
+		case "shell":
+			fmt.Fprintf(w, "export %s=%s\n", kv[0], shellQuote(kv[1]))
+		case "goenv":
+			fmt.Fprintf(w, "%s=%s\n", kv[0], kv[1])
+		default:
+			return fmt.Errorf("unknown format `%s`", format)
+		}
+	}
+	return nil
+}
+
+// ToolexecEnv 返回直接使用 go 命令构建时需要设置的环境变量：GOFLAGS 以及转发给 toolexec 的参数
+func ToolexecEnv(tags []string) ([][2]string, error) {
+	if strings.ContainsAny(AutobuildPath, " \t") {
+		return nil, fmt.Errorf("autobuild path `%s` contains spaces, not supported by GOFLAGS", AutobuildPath)
+	}
+	options, err := toolexecOptions(tags)
+	if err != nil {
+		return nil, err
+	}
+	toolexecFlags, err := utils.JoinQuoted(options)
+	if err != nil {
+		return nil, err
+	}
+	goflags := []string{"-a", "-toolexec=" + AutobuildPath}
+	if current := strings.TrimSpace(os.Getenv("GOFLAGS")); current != "" && !IsToolexecExist(strings.Fields(current)) {
+		goflags = append(strings.Fields(current), goflags...)
+	}
+	return [][2]string{
+		{"GOFLAGS", strings.Join(goflags, " ")},
+		{configs.TagCustomToolexecFlags, toolexecFlags},
+	}, nil
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -1,63 +1,178 @@
 package build
 
 import (
-	"bytes"
-	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 
+	"github.com/ListenOcean/goHookTool/configs"
 	"github.com/ListenOcean/goHookTool/internal/build/log"
+	"github.com/ListenOcean/goHookTool/utils"
 )
 
-func ForwardBuild() error {
-	args, err := AddToolexec()
+func ForwardBuild(goArgs []string) error {
+	args, err := AddToolexec(goArgs)
 	if err != nil {
 		return err
 	}
-	if err = DoBuildWithToolexec(args); err != nil {
+	buildFlags, err := utils.JoinQuoted(snippetBuildFlags(goArgs))
+	if err != nil {
+		return err
+	}
+	env := []string{configs.TagCustomGoBuildFlags + "=" + buildFlags}
+	if err = DoBuildWithToolexec(args, env); err != nil {
 		return err
 	}
 	return nil
 }
 
-func DoBuildWithToolexec(args []string) (err error) {
-	if len(os.Args) < 2 {
-		return fmt.Errorf("not enough args")
-	}
-	if _, _, err = ExecuteCmd(WorkDir, GoPath, args[1:], nil); err != nil {
+func DoBuildWithToolexec(args []string, env []string) (err error) {
+	if err = ExecuteCmd(WorkDir, GoPath, args, env); err != nil {
 		return
 	}
 	return nil
 }
 
+// Flags of go build taking a value, which can be the next argument.
+var goBuildValueFlags = map[string]bool{
+	"C": true, "o": true, "p": true, "asmflags": true, "buildmode": true,
+	"compiler": true, "covermode": true, "coverpkg": true, "gccgoflags": true,
+	"gcflags": true, "installsuffix": true, "ldflags": true, "mod": true,
+	"modfile": true, "overlay": true, "pgo": true, "pkgdir": true, "tags": true,
+	"toolexec": true,
+}
+
+// Flags of go build only concerning its own command: its directory, output,
+// logging and instrumentation.
+var goBuildCommandFlags = map[string]bool{
+	"C": true, "o": true, "n": true, "x": true, "v": true, "work": true,
+	"json": true, "a": true, "toolexec": true,
+}
+
+// snippetBuildFlags returns the go build flags, such as -race or -gcflags,
+// that toolexec forwards to the builds of the packages imported by the code
+// snippets so that they match the packages of the build.
+func snippetBuildFlags(goArgs []string) (flags []string) {
+	for i := 0; i < len(goArgs); i++ {
+		arg := goArgs[i]
+		if arg == "--" || !strings.HasPrefix(arg, "-") {
+			// The packages follow the flags.
+			break
+		}
+		name, _, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		flag := []string{arg}
+		if !hasValue && goBuildValueFlags[name] && i+1 < len(goArgs) {
+			i++
+			flag = append(flag, goArgs[i])
+		}
+		if !goBuildCommandFlags[name] {
+			flags = append(flags, flag...)
+		}
+	}
+	return flags
+}
+
 func IsToolexecExist(args []string) bool {
 	for _, arg := range args {
-		if strings.HasPrefix(arg, "-toolexec") {
+		if strings.HasPrefix(arg, "-toolexec") || strings.HasPrefix(arg, "--toolexec") {
 			return true
 		}
 	}
 	return false
 }
 
-func AddToolexec() (args []string, err error) {
-	var toolexecStr string
-	if !IsToolexecExist(os.Args) {
-		toolexecStr = `-toolexec=` + AutobuildPath + ` toolexec`
-		args = append(os.Args[:2], append([]string{toolexecStr, "-a"}, os.Args[2:]...)...)
-	} else {
+// AddToolexec 返回 go build 的参数，添加 -toolexec 以及 -a（所有包均需重新编译以生成完整的 Hook 表）
+func AddToolexec(goArgs []string) (args []string, err error) {
+	if IsToolexecExist(goArgs) {
 		log.Info("already has toolexec, skip hook")
-		args = os.Args
+		return append([]string{"build"}, goArgs...), nil
 	}
-	return
+	toolexecFlag, err := ToolexecFlag(BuildTags(goArgs))
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{"build", toolexecFlag, "-a"}, goArgs...), nil
+}
+
+// ToolexecFlag 返回 go 命令的 -toolexec 参数，其中包含转发给 toolexec 的选项以及构建标签
+func ToolexecFlag(tags []string) (string, error) {
+	options, err := toolexecOptions(tags)
+	if err != nil {
+		return "", err
+	}
+	toolexec, err := utils.JoinQuoted(append([]string{AutobuildPath, "toolexec"}, options...))
+	if err != nil {
+		return "", err
+	}
+	return "-toolexec=" + toolexec, nil
 }
 
-func ExecuteCmd(workdir string, program string, args []string, env []string) (stdout, stderr []byte, err error) {
-	var stdoutBuf, stderrBuf bytes.Buffer
+// toolexecOptions returns the options forwarded to autobuild's toolexec
+// command.
+func toolexecOptions(tags []string) ([]string, error) {
+	var args []string
+
+	configFile, err := ConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	if configFile != "" {
+		args = append(args, "-config="+configFile)
+	}
+	if len(tags) > 0 {
+		// The compile command is not given the build tags of the config
+		// section conditions.
+		args = append(args, "-tags="+strings.Join(tags, ","))
+	}
+	if Flags.Full {
+		args = append(args, "-full")
+	}
+	if Flags.Verbose {
+		args = append(args, "-v")
+	}
+	if Flags.Strict {
+		args = append(args, "-strict")
+	}
+	// toolexec is run from the package directories so paths are made absolute.
+	if Flags.KeepInstrumented != "" {
+		dir, err := filepath.Abs(Flags.KeepInstrumented)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "-keep-instrumented="+dir)
+	}
+	if Flags.LineDirectives != "" {
+		args = append(args, "-line-directives="+Flags.LineDirectives)
+	}
+	if Flags.Report != "" {
+		report, err := filepath.Abs(Flags.Report)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "-report="+report)
+	}
+	return args, nil
+}
+
+// PassthroughError is returned by ExecuteCmd when the program failed: its
+// errors were already shown through the passthrough of its standard streams.
+type PassthroughError struct {
+	*exec.ExitError
+}
+
+func (e *PassthroughError) Unwrap() error {
+	return e.ExitError
+}
+
+// ExecuteCmd runs the program with live passthrough of its standard streams.
+// The returned error is a *PassthroughError when the program failed.
+func ExecuteCmd(workdir string, program string, args []string, env []string) (err error) {
 	cmd := exec.Command(program, args...)
 	cmd.Dir = workdir
-	cmd.Stderr = &stdoutBuf
-	cmd.Stdout = &stderrBuf
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
 	log.Debug(
 		"Exec Command.",
 		log.String("workdir", cmd.Dir),
@@ -65,23 +180,13 @@ func ExecuteCmd(workdir string, program string, args []string, env []string) (st
 		log.String("args", strings.Join(cmd.Args, " ")),
 	)
 	cmd.Env = append(os.Environ(), env...)
-	err = cmd.Run()
-	stdout = stdoutBuf.Bytes()
-	stderr = stderrBuf.Bytes()
-	fmt.Println(stdoutBuf.String())
-	fmt.Println(stderrBuf.String())
-	if err != nil {
-		log.Error(
-			"Exec Result.",
-			log.String("stdout", stdoutBuf.String()),
-			log.String("stderr", stderrBuf.String()),
-		)
+	if err = cmd.Run(); err != nil {
+		log.Debug("Exec Result.", log.String("err", err.Error()))
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return &PassthroughError{exitErr}
+		}
 		return
 	}
-	log.Debug(
-		"Exec Result.",
-		log.String("stdout", stdoutBuf.String()),
-		log.String("stderr", stderrBuf.String()),
-	)
+	log.Debug("Exec Result.", log.Int("exitCode", cmd.ProcessState.ExitCode()))
 	return
 }
@@ -0,0 +1,66 @@
+package build
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/ListenOcean/goHookTool/internal/build/log"
+)
+
+// Package is the subset of `go list -json` fields used by autobuild.
+type Package struct {
+	ImportPath string
+	Name       string
+	Dir        string
+	GoFiles    []string
+	Standard   bool
+}
+
+// CompilePkgPath returns the package path given to the compiler with `-p`.
+func (p *Package) CompilePkgPath() string {
+	if p.Name == "main" {
+		return "main"
+	}
+	return p.ImportPath
+}
+
+// ListPackages 通过 `go list -json` 加载匹配的包，deps 为真时包括其全部依赖
+func ListPackages(patterns []string, deps bool) ([]*Package, error) {
+	args := []string{"list", "-json"}
+	if deps {
+		args = append(args, "-deps")
+	}
+	args = append(args, patterns...)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(GoPath, args...)
+	cmd.Dir = WorkDir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	log.Debug(
+		"Exec Command.",
+		log.String("workdir", cmd.Dir),
+		log.String("program", cmd.Path),
+		log.String("args", strings.Join(cmd.Args, " ")),
+	)
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("go list: %w\n%s", err, stderr.String())
+	}
+
+	var pkgs []*Package
+	decoder := json.NewDecoder(&stdout)
+	for {
+		pkg := &Package{}
+		if err := decoder.Decode(pkg); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs, nil
+}
@@ -0,0 +1,63 @@
+package build
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/ListenOcean/goHookTool/configs"
+)
+
+// Target 通过 `go env` 返回构建的目标环境，用于 plan 等不经过 toolexec 的命令
+func Target(tags []string) (configs.Target, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(GoPath, "env", "GOVERSION", "GOOS", "GOARCH")
+	cmd.Dir = WorkDir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return configs.Target{}, fmt.Errorf("go env: %w\n%s", err, stderr.String())
+	}
+	env := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(env) != 3 {
+		return configs.Target{}, fmt.Errorf("unexpected `go env` output %q", stdout.String())
+	}
+	return configs.Target{GoVersion: env[0], GOOS: env[1], GOARCH: env[2], Tags: tags}, nil
+}
+
+// BuildTags returns the build tags of the go build arguments, or of GOFLAGS
+// when they have none.
+func BuildTags(goArgs []string) []string {
+	if tags, ok := tagsFlag(goArgs); ok {
+		return tags
+	}
+	tags, _ := tagsFlag(strings.Fields(os.Getenv("GOFLAGS")))
+	return tags
+}
+
+func tagsFlag(args []string) (tags []string, ok bool) {
+	for i := 0; i < len(args); i++ {
+		arg := strings.TrimPrefix(args[i], "-")
+		var value string
+		if strings.HasPrefix(arg, "-tags=") || strings.HasPrefix(arg, "tags=") {
+			value = arg[strings.Index(arg, "=")+1:]
+		} else if (arg == "-tags" || arg == "tags") && i+1 < len(args) {
+			i++
+			value = args[i]
+		} else {
+			continue
+		}
+		// The last one wins, as with the go command.
+		tags, ok = splitTags(value), true
+	}
+	return tags, ok
+}
+
+// Build tags are comma-separated, or space-separated with older go releases.
+func splitTags(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/ListenOcean/goHookTool/configs"
+	"github.com/ListenOcean/goHookTool/internal/build"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var ConfigCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the hook configuration.",
+}
+
+var ValidateCmd = &cobra.Command{
+	Use:   "validate [files]",
+	Short: "Check the hook configuration, without building.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  ValidateEntry,
+}
+
+var PrintCmd = &cobra.Command{
+	Use:   "print [files]",
+	Short: "Print the hook configuration files, or the merged configuration.",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  PrintEntry,
+}
+
+var resolved bool
+
+func init() {
+	build.AddCommonFlags(ValidateCmd)
+	build.AddCommonFlags(PrintCmd)
+	PrintCmd.Flags().BoolVar(&resolved, "resolved", false, "print the configuration merged for the build target")
+	ConfigCmd.AddCommand(ValidateCmd)
+	ConfigCmd.AddCommand(PrintCmd)
+}
+
+func ValidateEntry(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+	configFile, err := configPath(args)
+	if err != nil {
+		return err
+	}
+
+	warnings, err := configs.ValidateConfigFiles(configFile)
+	for _, d := range warnings {
+		fmt.Fprintln(os.Stderr, d.String())
+	}
+	var validationErr *configs.ValidationError
+	if errors.As(err, &validationErr) {
+		for _, d := range validationErr.Diagnostics {
+			fmt.Fprintln(os.Stderr, d.String())
+		}
+		return fmt.Errorf("%d problem(s) found", len(validationErr.Diagnostics))
+	}
+	if err != nil {
+		return err
+	}
+	files, err := configs.ConfigFiles(configFile)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		fmt.Printf("%s: ok\n", file)
+	}
+	return nil
+}
+
+func PrintEntry(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+	configFile, err := configPath(args)
+	if err != nil {
+		return err
+	}
+	files, err := configs.ConfigFiles(configFile)
+	if err != nil {
+		return err
+	}
+	if resolved {
+		return printResolved(os.Stdout, configFile, files)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("# %s\n%s", file, data)
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			fmt.Println()
+		}
+	}
+	return nil
+}
+
+// printResolved prints the configuration autobuild build would use, once the
+// files and their sections are merged.
+func printResolved(w io.Writer, configFile string, files []string) error {
+	target, err := build.Target(build.BuildTags(nil))
+	if err != nil {
+		return err
+	}
+	if err := configs.ReadConfig(configFile, target); err != nil {
+		return err
+	}
+	var data bytes.Buffer
+	encoder := yaml.NewEncoder(&data)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&configs.ConfigData); err != nil {
+		return err
+	}
+	for _, file := range files {
+		fmt.Fprintf(w, "# %s\n", file)
+	}
+	fmt.Fprintf(w, "# target: %s %s/%s", target.GoVersion, target.GOOS, target.GOARCH)
+	if len(target.Tags) > 0 {
+		fmt.Fprintf(w, " tags %v", target.Tags)
+	}
+	fmt.Fprintf(w, "\n%s", data.Bytes())
+	return nil
+}
+
+// configPath returns the config files of the argument, or the ones autobuild
+// build would use.
+func configPath(args []string) (string, error) {
+	if err := build.InitPaths(); err != nil {
+		return "", err
+	}
+	if len(args) > 0 {
+		build.Flags.Config = args[0]
+	}
+	configFile, err := build.ConfigPath()
+	if err != nil {
+		return "", err
+	}
+	if configFile == "" {
+		return "", configs.ErrNoConfig
+	}
+	return configFile, nil
+}
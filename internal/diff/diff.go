@@ -0,0 +1,89 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	stdlog "log"
+	"os"
+	"path/filepath"
+
+	"github.com/ListenOcean/goHookTool/configs"
+	"github.com/ListenOcean/goHookTool/internal/build"
+	"github.com/ListenOcean/goHookTool/internal/toolexec/instrument"
+
+	"github.com/spf13/cobra"
+)
+
+var DiffCmd = &cobra.Command{
+	Use:   "diff <packages>",
+	Short: "Show the instrumentation generated for a package.",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  DiffEntry,
+}
+
+func init() {
+	build.AddCommonFlags(DiffCmd)
+}
+
+func DiffEntry(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+	// The instrumentation logs are meant for the toolexec log file.
+	stdlog.SetOutput(io.Discard)
+
+	if err := build.InitPaths(); err != nil {
+		return err
+	}
+	configFile, err := build.ConfigPath()
+	if err != nil {
+		return err
+	}
+	target, err := build.Target(build.BuildTags(nil))
+	if err != nil {
+		return err
+	}
+	if err := configs.ReadConfig(configFile, target); err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+
+	pkgs, err := build.ListPackages(args, false)
+	if err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		if err := diffPackage(os.Stdout, pkg); err != nil {
+			return fmt.Errorf("package `%s`: %w", pkg.ImportPath, err)
+		}
+	}
+	return nil
+}
+
+// diffPackage 对包进行插桩，并输出原始文件与插桩后文件的 unified diff
+func diffPackage(w io.Writer, pkg *build.Package) error {
+	files := make([]string, 0, len(pkg.GoFiles))
+	for _, file := range pkg.GoFiles {
+		files = append(files, filepath.Join(pkg.Dir, file))
+	}
+	plan, err := instrument.DryRun(pkg.CompilePkgPath(), files, false)
+	if err != nil {
+		return err
+	}
+	if len(plan.Sources) == 0 {
+		fmt.Fprintf(os.Stderr, "package `%s`: nothing instrumented\n", pkg.ImportPath)
+		return nil
+	}
+
+	for _, src := range files {
+		instrumented, ok := plan.Sources[src]
+		if !ok {
+			continue
+		}
+		original, err := os.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		if err := Unified(w, src, src+" (instrumented)", string(original), instrumented); err != nil {
+			return err
+		}
+	}
+	return nil
+}
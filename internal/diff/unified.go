@@ -0,0 +1,206 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Number of unchanged lines shown around each change.
+const contextLines = 3
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type op struct {
+	kind opKind
+	// Line indices in the old and new line slices.
+	i, j int
+}
+
+// Unified writes into w the unified diff between the old and new texts, or
+// nothing when they are equal.
+func Unified(w io.Writer, oldLabel, newLabel, oldText, newText string) error {
+	a, b := splitLines(oldText), splitLines(newText)
+	ops := editScript(a, b)
+
+	hunks := makeHunks(ops)
+	if len(hunks) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(w, "--- %s\n+++ %s\n", oldLabel, newLabel); err != nil {
+		return err
+	}
+	for _, h := range hunks {
+		if err := writeHunk(w, h, a, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// editScript returns the shortest edit script turning a into b using the
+// Myers diff algorithm.
+func editScript(a, b []string) []op {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	// Copy of v before each step d, used to backtrack the path.
+	var trace [][]int
+
+loop:
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break loop
+			}
+		}
+	}
+
+	// Backtrack the trace to build the edit script in reverse order.
+	var ops []op
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, op{kind: opEqual, i: x, j: y})
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, op{kind: opInsert, i: x, j: prevY})
+			} else {
+				ops = append(ops, op{kind: opDelete, i: prevX, j: y})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for l, r := 0, len(ops)-1; l < r; l, r = l+1, r-1 {
+		ops[l], ops[r] = ops[r], ops[l]
+	}
+	return ops
+}
+
+// Group the edit operations into hunks of changes surrounded by context lines.
+func makeHunks(ops []op) [][]op {
+	var hunks [][]op
+	start := -1
+	lastChange := -1
+	for idx, o := range ops {
+		if o.kind == opEqual {
+			continue
+		}
+		// Hunks whose context lines are adjacent are merged, as with diff -u.
+		if start != -1 && idx-lastChange-1 > 2*contextLines {
+			hunks = append(hunks, ops[start:minInt(lastChange+contextLines+1, len(ops))])
+			start = -1
+		}
+		if start == -1 {
+			start = maxInt(idx-contextLines, 0)
+		}
+		lastChange = idx
+	}
+	if start != -1 {
+		hunks = append(hunks, ops[start:minInt(lastChange+contextLines+1, len(ops))])
+	}
+	return hunks
+}
+
+func writeHunk(w io.Writer, h []op, a, b []string) error {
+	var oldCount, newCount int
+	for _, o := range h {
+		switch o.kind {
+		case opEqual:
+			oldCount++
+			newCount++
+		case opDelete:
+			oldCount++
+		case opInsert:
+			newCount++
+		}
+	}
+	oldStart, newStart := h[0].i+1, h[0].j+1
+	if oldCount == 0 {
+		oldStart--
+	}
+	if newCount == 0 {
+		newStart--
+	}
+	if _, err := fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount); err != nil {
+		return err
+	}
+
+	for _, o := range h {
+		var prefix, line string
+		switch o.kind {
+		case opEqual:
+			prefix, line = " ", a[o.i]
+		case opDelete:
+			prefix, line = "-", a[o.i]
+		case opInsert:
+			prefix, line = "+", b[o.j]
+		}
+		if !strings.HasSuffix(line, "\n") {
+			line += "\n\\ No newline at end of file\n"
+		}
+		if _, err := io.WriteString(w, prefix+line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,132 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{
+			name: "equal",
+			old:  "a\nb\n",
+			new:  "a\nb\n",
+			want: "",
+		},
+		{
+			name: "both empty",
+			want: "",
+		},
+		{
+			name: "added file content",
+			new:  "a\nb\n",
+			want: "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "removed file content",
+			old:  "a\nb\n",
+			want: "--- old\n+++ new\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name: "changed line",
+			old:  "a\nb\nc\n",
+			new:  "a\nB\nc\n",
+			want: "--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name: "inserted line",
+			old:  "a\nc\n",
+			new:  "a\nb\nc\n",
+			want: "--- old\n+++ new\n@@ -1,2 +1,3 @@\n a\n+b\n c\n",
+		},
+		{
+			name: "context lines",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			new:  "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			want: "--- old\n+++ new\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "separate hunks",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			new:  "one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n",
+			want: "--- old\n+++ new\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+ten\n",
+		},
+		{
+			name: "hunks one line apart",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			new:  "one\n2\n3\n4\n5\n6\n7\n8\nnine\n",
+			want: "--- old\n+++ new\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -6,4 +6,4 @@\n 6\n 7\n 8\n-9\n+nine\n",
+		},
+		{
+			name: "adjacent hunks",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n",
+			new:  "one\n2\n3\n4\n5\n6\n7\neight\n",
+			want: "--- old\n+++ new\n@@ -1,8 +1,8 @@\n-1\n+one\n 2\n 3\n 4\n 5\n 6\n 7\n-8\n+eight\n",
+		},
+		{
+			name: "no newline at end of file",
+			old:  "a\nb",
+			new:  "a\nb\n",
+			want: "--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var out strings.Builder
+			if err := Unified(&out, "old", "new", tc.old, tc.new); err != nil {
+				t.Fatal(err)
+			}
+			if got := out.String(); got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEditScript(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		// Length of the shortest edit script.
+		edits int
+	}{
+		{a: "", b: "", edits: 0},
+		{a: "abc", b: "abc", edits: 0},
+		{a: "", b: "abc", edits: 3},
+		{a: "abc", b: "", edits: 3},
+		{a: "abc", b: "abd", edits: 2},
+		{a: "abcabba", b: "cbabac", edits: 5},
+		{a: "xaxbx", b: "abc", edits: 4},
+	} {
+		t.Run(tc.a+"/"+tc.b, func(t *testing.T) {
+			a, b := strings.Split(tc.a, ""), strings.Split(tc.b, "")
+			ops := editScript(a, b)
+
+			// Apply the edit script to a.
+			var got []string
+			edits := 0
+			for _, o := range ops {
+				switch o.kind {
+				case opEqual:
+					if a[o.i] != b[o.j] {
+						t.Fatalf("unequal lines %q and %q", a[o.i], b[o.j])
+					}
+					got = append(got, a[o.i])
+				case opDelete:
+					edits++
+				case opInsert:
+					got = append(got, b[o.j])
+					edits++
+				}
+			}
+			if strings.Join(got, "") != tc.b {
+				t.Errorf("edit script gives %q, want %q", strings.Join(got, ""), tc.b)
+			}
+			if edits != tc.edits {
+				t.Errorf("got %d edits, want %d", edits, tc.edits)
+			}
+		})
+	}
+}
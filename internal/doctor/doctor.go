@@ -0,0 +1,309 @@
+package doctor
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ListenOcean/goHookTool/configs"
+	"github.com/ListenOcean/goHookTool/internal/build"
+
+	"github.com/spf13/cobra"
+)
+
+var DoctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the go toolchain is compatible with autobuild.",
+	Args:  cobra.NoArgs,
+	RunE:  DoctorEntry,
+}
+
+func init() {
+	build.AddCommonFlags(DoctorCmd)
+}
+
+// check is the result of a doctor check.
+type check struct {
+	name   string
+	err    error
+	detail string
+	// How to fix the failed check.
+	remedy string
+}
+
+// toolchain is the go toolchain information the checks rely on.
+type toolchain struct {
+	version string
+	goroot  string
+	tooldir string
+}
+
+func DoctorEntry(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+	if err := build.InitPaths(); err != nil {
+		return err
+	}
+
+	var checks []check
+	tc, c := checkGo()
+	checks = append(checks, c)
+	if c.err == nil {
+		checks = append(checks,
+			checkCompiler(tc),
+			checkRuntimeExtension(tc, configs.RuntimeExtraFileContent(tc.version)),
+			checkSampleBuild(),
+		)
+	}
+
+	failed := printChecks(os.Stdout, checks)
+	if failed > 0 {
+		return fmt.Errorf("%d check(s) failed", failed)
+	}
+	return nil
+}
+
+func printChecks(w io.Writer, checks []check) (failed int) {
+	for _, c := range checks {
+		if c.err == nil {
+			fmt.Fprintf(w, "[ok]   %s: %s\n", c.name, c.detail)
+			continue
+		}
+		failed++
+		fmt.Fprintf(w, "[fail] %s: %v\n", c.name, c.err)
+		if c.remedy != "" {
+			fmt.Fprintf(w, "       remedy: %s\n", c.remedy)
+		}
+	}
+	return failed
+}
+
+// checkGo checks the chosen go binary runs and returns its toolchain.
+func checkGo() (toolchain, check) {
+	c := check{name: "go binary"}
+	out, err := goOutput("env", "GOVERSION", "GOROOT", "GOTOOLDIR")
+	if err != nil {
+		c.err = err
+		c.remedy = "select a working go binary with --go or $" + configs.TagCustomGoBin
+		return toolchain{}, c
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 {
+		c.err = fmt.Errorf("unexpected `go env` output %q", out)
+		c.remedy = "select a go binary of a release supporting `go env GOVERSION`"
+		return toolchain{}, c
+	}
+	tc := toolchain{version: lines[0], goroot: lines[1], tooldir: lines[2]}
+	c.detail = fmt.Sprintf("%s %s (GOROOT %s)", build.GoPath, tc.version, tc.goroot)
+	return tc, c
+}
+
+// checkCompiler checks the compile tool intercepted with -toolexec exists.
+func checkCompiler(tc toolchain) check {
+	c := check{name: "compile tool"}
+	compile := filepath.Join(tc.tooldir, "compile")
+	if _, err := os.Stat(compile); err != nil {
+		if _, err := os.Stat(compile + ".exe"); err != nil {
+			c.err = fmt.Errorf("no compile tool in GOTOOLDIR `%s`", tc.tooldir)
+			c.remedy = "reinstall the go toolchain"
+			return c
+		}
+		compile += ".exe"
+	}
+	c.detail = compile
+	return c
+}
+
+// checkRuntimeExtension checks the runtime internals used by the extension
+// file added to the runtime package exist in the toolchain sources: the
+// imported packages and their functions.
+func checkRuntimeExtension(tc toolchain, content string) check {
+	c := check{
+		name:   "runtime internals",
+		remedy: fmt.Sprintf("%s is not supported by this autobuild version, use another go release or update autobuild", tc.version),
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "autobuild.go", content, 0)
+	if err != nil {
+		c.err = err
+		return c
+	}
+
+	used := usedImportedIdents(file)
+	var checked []string
+	for _, spec := range file.Imports {
+		pkgPath, _ := strconv.Unquote(spec.Path.Value)
+		if pkgPath == "unsafe" {
+			continue
+		}
+		dir := filepath.Join(tc.goroot, "src", filepath.FromSlash(pkgPath))
+		decls, err := packageFuncDecls(dir)
+		if err != nil {
+			c.err = fmt.Errorf("package `%s` not found in GOROOT", pkgPath)
+			return c
+		}
+		for ident := range used[importName(spec, pkgPath)] {
+			if _, ok := decls[ident]; !ok {
+				c.err = fmt.Errorf("function `%s.%s` not found in GOROOT", pkgPath, ident)
+				return c
+			}
+			checked = append(checked, pkgPath+"."+ident)
+		}
+	}
+	c.detail = strings.Join(checked, ", ")
+	return c
+}
+
+// usedImportedIdents returns the identifiers selected from each imported
+// package name of the file.
+func usedImportedIdents(file *ast.File) map[string]map[string]struct{} {
+	used := make(map[string]map[string]struct{})
+	ast.Inspect(file, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if x, ok := sel.X.(*ast.Ident); ok {
+			if used[x.Name] == nil {
+				used[x.Name] = make(map[string]struct{})
+			}
+			used[x.Name][sel.Sel.Name] = struct{}{}
+		}
+		return true
+	})
+	return used
+}
+
+func importName(spec *ast.ImportSpec, pkgPath string) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	return filepath.Base(pkgPath)
+}
+
+// packageFuncDecls returns the set of top-level function names of the package
+// in dir, whatever their build constraints.
+func packageFuncDecls(dir string) (map[string]struct{}, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) == 0 {
+		return nil, fmt.Errorf("no go files in `%s`", dir)
+	}
+	decls := make(map[string]struct{})
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				if funcDecl, ok := decl.(*ast.FuncDecl); ok && funcDecl.Recv == nil {
+					decls[funcDecl.Name.Name] = struct{}{}
+				}
+			}
+		}
+	}
+	return decls, nil
+}
+
+const (
+	sampleModule = "autobuild.doctor/sample"
+	sampleGoMod  = "module " + sampleModule + "\n"
+	sampleMain   = `package main
+
+import (
+	"fmt"
+
+	"` + sampleModule + `/greet"
+)
+
+func main() {
+	fmt.Println(greet.Hello("doctor"))
+}
+`
+	sampleGreet = `package greet
+
+func Hello(name string) string {
+	return "hello " + name
+}
+`
+	sampleConfig = `hookpoints:
+  ` + sampleModule + `/greet:
+    - ` + sampleModule + `/greet.Hello
+`
+	sampleOutput = "hello doctor\n"
+)
+
+// checkSampleBuild builds a tiny program with a hookpoint using autobuild,
+// in strict mode, and runs it.
+func checkSampleBuild() check {
+	c := check{
+		name:   "instrumented build",
+		remedy: "run the sample build with --verbose to see the instrumentation logs",
+	}
+	dir, err := os.MkdirTemp("", "autobuild-doctor-")
+	if err != nil {
+		c.err = err
+		return c
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"go.mod":                      sampleGoMod,
+		"main.go":                     sampleMain,
+		"greet/greet.go":              sampleGreet,
+		configs.DefaultConfigFileName: sampleConfig,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			c.err = err
+			return c
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			c.err = err
+			return c
+		}
+	}
+
+	bin := filepath.Join(dir, "sample")
+	config := filepath.Join(dir, configs.DefaultConfigFileName)
+	cmd := exec.Command(build.AutobuildPath, "build", "--go="+build.GoPath, "--config="+config, "--strict", "--", "-o", bin, ".")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		c.err = fmt.Errorf("%v\n%s", err, indent(string(out)))
+		return c
+	}
+	out, err := exec.Command(bin).CombinedOutput()
+	if err != nil || string(out) != sampleOutput {
+		c.err = fmt.Errorf("unexpected sample program result %q (%v)", out, err)
+		c.remedy = "the instrumentation breaks the program, please report it with `autobuild diff`"
+		return c
+	}
+	c.detail = "sample program hooked and run"
+	return c
+}
+
+func goOutput(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(build.GoPath, args...)
+	cmd.Dir = build.WorkDir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("go %s: %w\n%s", strings.Join(args, " "), err, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
+func indent(s string) string {
+	return "       " + strings.ReplaceAll(strings.TrimSpace(s), "\n", "\n       ")
+}
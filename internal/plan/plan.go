@@ -0,0 +1,128 @@
+package plan
+
+import (
+	"fmt"
+	"io"
+	stdlog "log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ListenOcean/goHookTool/configs"
+	"github.com/ListenOcean/goHookTool/internal/build"
+	"github.com/ListenOcean/goHookTool/internal/toolexec/instrument"
+
+	"github.com/spf13/cobra"
+)
+
+var PlanCmd = &cobra.Command{
+	Use:   "plan [packages]",
+	Short: "Preview which functions would be hooked, without building.",
+	RunE:  PlanEntry,
+}
+
+func init() {
+	build.AddCommonFlags(PlanCmd)
+}
+
+func PlanEntry(cmd *cobra.Command, args []string) error {
+	cmd.SilenceUsage = true
+	// The instrumentation logs are meant for the toolexec log file.
+	stdlog.SetOutput(io.Discard)
+
+	if err := build.InitPaths(); err != nil {
+		return err
+	}
+	configFile, err := build.ConfigPath()
+	if err != nil {
+		return err
+	}
+	target, err := build.Target(build.BuildTags(nil))
+	if err != nil {
+		return err
+	}
+	if err := configs.ReadConfig(configFile, target); err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+
+	plans, err := Plan(args)
+	if err != nil {
+		return err
+	}
+	return printPlans(os.Stdout, plans)
+}
+
+// Plan 对 patterns 匹配的包及其依赖进行插桩预演，返回每个配置了 Hook 点的包的结果
+func Plan(patterns []string) ([]*instrument.PackagePlan, error) {
+	pkgs, err := build.ListPackages(patterns, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var plans []*instrument.PackagePlan
+	found := make(map[string]struct{})
+	for _, pkg := range pkgs {
+		pkgPath := instrument.UnvendorPackagePath(pkg.CompilePkgPath())
+		if pkgPath == "main" {
+			if plan := instrument.MainPackagePlan(pkg.ImportPath); plan != nil {
+				plans = append(plans, plan)
+			}
+		}
+		if !configs.HasHookpoints(pkgPath) {
+			continue
+		}
+		found[pkgPath] = struct{}{}
+		for _, w := range configs.PackageWildcards {
+			if w.Match(pkgPath) {
+				found[w.Pattern] = struct{}{}
+			}
+		}
+
+		files := make([]string, 0, len(pkg.GoFiles))
+		for _, file := range pkg.GoFiles {
+			files = append(files, filepath.Join(pkg.Dir, file))
+		}
+		plan, err := instrument.DryRun(pkgPath, files, false)
+		if err != nil {
+			return nil, fmt.Errorf("package `%s`: %w", pkg.ImportPath, err)
+		}
+		plans = append(plans, plan)
+	}
+
+	for pkgPath := range configs.ConfigData.Hookpoints {
+		if _, ok := found[pkgPath]; !ok {
+			plans = append(plans, instrument.NotFoundPlan(pkgPath))
+		}
+	}
+	sort.SliceStable(plans, func(i, j int) bool {
+		return plans[i].PkgPath < plans[j].PkgPath
+	})
+	return plans, nil
+}
+
+func printPlans(w io.Writer, plans []*instrument.PackagePlan) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, plan := range plans {
+		fmt.Fprintf(tw, "%s\n", plan.PkgPath)
+		for _, hookpoint := range plan.Hookpoints {
+			reason := hookpoint.Reason
+			if len(hookpoint.Closest) > 0 {
+				reason += "; closest: " + strings.Join(hookpoint.Closest, ", ")
+			}
+			if reason != "" {
+				fmt.Fprintf(tw, "  %s\t%s\t(%s)\n", hookpoint.Status, hookpoint.Signature, reason)
+			} else {
+				fmt.Fprintf(tw, "  %s\t%s\n", hookpoint.Status, hookpoint.Signature)
+			}
+			for _, signatrue := range hookpoint.Expanded {
+				fmt.Fprintf(tw, "  \t  -> %s\n", signatrue)
+			}
+		}
+	}
+	return tw.Flush()
+}
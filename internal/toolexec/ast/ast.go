@@ -5,6 +5,7 @@ import (
 	"go/token"
 	"log"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/ListenOcean/goHookTool/configs"
@@ -16,15 +17,27 @@ import (
 // The hookpoint structure holds every AST node required during the
 // instrumentation of a file.
 type Hookpoint struct {
+	Signature           string
+	ID                  string
 	DescriptorFuncDecl  *dst.FuncDecl
 	PrologVarDecl       *dst.GenDecl
 	PrologLoadFuncDecl  *dst.FuncDecl
 	InstrumentationStmt dst.Stmt
+	// Packages imported by the code snippets, with the names they are
+	// referenced with in the instrumentation statement.
+	Imports []configs.SnippetImport
+	// Package-level declarations of the built-in hook kind.
+	Decls []dst.Decl
 }
 
-func GetHookpoint(signatrue, id string, funcDecl *dst.FuncDecl, descriptorValueInitializer HookDescriptorValueInitializer) *Hookpoint {
-	epilogFuncType, epilogCallArgs := newEpilogFuncType(funcDecl.Type)
-	prologFuncType, prologCallArgs := newPrologFuncType(funcDecl, epilogFuncType)
+// GetHookpoint returns the hookpoint of the function declaration. The hook
+// descriptor references the function with `funcValue`.
+func GetHookpoint(signatrue, id string, funcDecl *dst.FuncDecl, funcValue dst.Expr, descriptorValueInitializer HookDescriptorValueInitializer) (*Hookpoint, error) {
+	// The callbacks are package-level variables which cannot reference the
+	// type parameters: their parameters using them have type `interface{}`.
+	typeParams := typeParamNames(funcDecl)
+	epilogFuncType, epilogCallArgs := newEpilogFuncType(funcDecl.Type, typeParams)
+	prologFuncType, prologCallArgs := newPrologFuncType(funcDecl, epilogFuncType, typeParams)
 
 	prologVarIdent := fmt.Sprintf(configs.PrologVarIdentFormat, id)
 	prologVarDecl, prologValueSpec := newPrologVarDecl(prologVarIdent, prologFuncType)
@@ -33,42 +46,86 @@ func GetHookpoint(signatrue, id string, funcDecl *dst.FuncDecl, descriptorValueI
 	prologLoadFuncDecl := newPrologLoadFuncDecl(prologLoadFuncIdent, prologValueSpec)
 
 	descriptorFuncIdent := fmt.Sprintf(configs.HookDescriptorFuncIdentFormat, id)
-	descriptorFuncDecl := newHookDescriptorFuncDecl(descriptorFuncIdent, funcDecl, prologVarIdent, descriptorValueInitializer)
+	descriptorFuncDecl := newHookDescriptorFuncDecl(descriptorFuncIdent, funcValue, prologVarIdent, descriptorValueInitializer)
 
-	instrumentationStmt := newInstrumentationStmt(prologLoadFuncIdent, prologCallArgs, epilogCallArgs, id, signatrue)
+	snippetData := newSnippetData(signatrue, id, funcDecl)
+	instrumentationStmt, err := newInstrumentationStmt(prologLoadFuncIdent, prologCallArgs, epilogCallArgs, snippetData)
+	if err != nil {
+		return nil, err
+	}
+	code := configs.ConfigData.Codes[signatrue]
+	importSpecs := code.Imports
+	var decls []dst.Decl
+	if code.Kind != "" {
+		kind, err := configs.LookupHookKind(code.Kind)
+		if err != nil {
+			return nil, fmt.Errorf("`%s`: %w", signatrue, err)
+		}
+		instrumentationStmt, decls, err = newKindInstrumentation(code.Kind, kind, snippetData, instrumentationStmt)
+		if err != nil {
+			return nil, fmt.Errorf("%s of `%s`: %w", code.Kind, signatrue, err)
+		}
+		importSpecs = append(append(configs.StringList{}, importSpecs...), kind.Imports...)
+	}
+	imports, err := renameSnippetImports(instrumentationStmt, importSpecs)
+	if err != nil {
+		return nil, fmt.Errorf("imports of `%s`: %w", signatrue, err)
+	}
 
 	return &Hookpoint{
+		Signature:           signatrue,
+		ID:                  id,
 		PrologLoadFuncDecl:  prologLoadFuncDecl,
 		DescriptorFuncDecl:  descriptorFuncDecl,
 		PrologVarDecl:       prologVarDecl,
 		InstrumentationStmt: instrumentationStmt,
-	}
+		Imports:             imports,
+		Decls:               decls,
+	}, nil
 }
 
-func NewHookpoint(signatrue string, pkgPath string, funcDecl *dst.FuncDecl, descriptorTypeIdent string, descriptorValueInitializer HookDescriptorValueInitializer) *Hookpoint {
+func NewHookpoint(signatrue string, pkgPath string, funcDecl *dst.FuncDecl, descriptorTypeIdent string, descriptorValueInitializer HookDescriptorValueInitializer) (*Hookpoint, error) {
 	id := normalizedHookpointID(pkgPath, funcDecl)
 	log.Printf("Hookpoint id: %s\n", id)
-	return GetHookpoint(signatrue, id, funcDecl, descriptorValueInitializer)
+	funcValue := newFunctionValueExpr(funcDecl)
+	if IsGenericFuncDecl(funcDecl) {
+		// Generic functions have no function value without instantiation: the
+		// hook descriptor gives the symbol instead, with the syntax of the
+		// runtime, eg. `pkg.Map[...]`.
+		funcValue = &dst.BasicLit{Kind: token.STRING, Value: strconv.Quote(signatrue)}
+	}
+	return GetHookpoint(signatrue, id, funcDecl, funcValue, descriptorValueInitializer)
+}
+
+// NewInitHookpoint returns the hookpoint of the `index`-th package init
+// function of file `filename`. Init functions cannot be referenced: the hook
+// descriptor gives the symbol instead, shared by every init function of the
+// package.
+func NewInitHookpoint(signatrue string, pkgPath string, filename string, index int, funcDecl *dst.FuncDecl, descriptorValueInitializer HookDescriptorValueInitializer) (*Hookpoint, error) {
+	id := configs.InitID(normalizedHookpointID(pkgPath, funcDecl), filename, index)
+	log.Printf("Init hookpoint id: %s\n", id)
+	funcValue := &dst.BasicLit{Kind: token.STRING, Value: strconv.Quote(signatrue)}
+	return GetHookpoint(signatrue, id, funcDecl, funcValue, descriptorValueInitializer)
+}
+
+// CallsiteID returns the hookpoint ID of the callsites of function `name` of
+// package `pkgPath` in package `callerPkgPath`.
+func CallsiteID(pkgPath, name, callerPkgPath string) string {
+	return configs.CallsiteID(normalizedPkgPath(pkgPath)+"_"+name, normalizedPkgPath(callerPkgPath))
+}
+
+// NewCallsiteHookpoint returns the hookpoint of the callsite wrapper function
+// declaration of the target function `target`. The hook descriptor
+// references the target function so that the hook has its symbol.
+func NewCallsiteHookpoint(signatrue, id string, wrapper *dst.FuncDecl, target dst.Expr, descriptorValueInitializer HookDescriptorValueInitializer) (*Hookpoint, error) {
+	log.Printf("Callsite hookpoint id: %s\n", id)
+	return GetHookpoint(signatrue, id, wrapper, target, descriptorValueInitializer)
 }
 
 func normalizedHookpointID(pkgPath string, node *dst.FuncDecl) string {
 	var receiver string
 	if node.Recv != nil {
-		t := node.Recv.List[0].Type
-	loop:
-		for {
-			switch actual := t.(type) {
-			default:
-				log.Fatalf("unexpected type %T\n", actual)
-
-			case *dst.StarExpr:
-				t = actual.X
-
-			case *dst.Ident:
-				receiver = actual.Name
-				break loop
-			}
-		}
+		receiver, _, _ = ReceiverType(node)
 		receiver += "_"
 	}
 	pkgPath = normalizedPkgPath(pkgPath)
@@ -128,12 +185,16 @@ func newPrologLoadFuncDecl(ident string, prologVarSpec *dst.ValueSpec) *dst.Func
 	}
 }
 
-func GetEpilogBody(epilogCallArgs []dst.Expr, signatrue string) *dst.BlockStmt {
-	if customCode, ok := configs.ConfigData.Codes[signatrue]; ok {
+func GetEpilogBody(epilogCallArgs []dst.Expr, snippetData *configs.SnippetData) (*dst.BlockStmt, error) {
+	if customCode, ok := configs.ConfigData.Codes[snippetData.Symbol]; ok {
 		if customCode.Epilog != "" {
-			return &dst.BlockStmt{
-				List: GetBlockAst(customCode.Epilog),
+			list, err := GetSnippetAst("epilog", customCode.Epilog, snippetData)
+			if err != nil {
+				return nil, fmt.Errorf("epilog of `%s`: %w", snippetData.Symbol, err)
 			}
+			return &dst.BlockStmt{
+				List: list,
+			}, nil
 		}
 	}
 
@@ -163,13 +224,17 @@ func GetEpilogBody(epilogCallArgs []dst.Expr, signatrue string) *dst.BlockStmt {
 		List: []dst.Stmt{
 			&epilogDefer,
 		},
-	}
+	}, nil
 }
 
-func GetProloglogBody(prologCallArgs []dst.Expr, signatrue string) []dst.Stmt {
-	if customCode, ok := configs.ConfigData.Codes[signatrue]; ok {
+func GetProloglogBody(prologCallArgs []dst.Expr, snippetData *configs.SnippetData) ([]dst.Stmt, error) {
+	if customCode, ok := configs.ConfigData.Codes[snippetData.Symbol]; ok {
 		if customCode.Prolog != "" {
-			return GetBlockAst(customCode.Prolog)
+			list, err := GetSnippetAst("prolog", customCode.Prolog, snippetData)
+			if err != nil {
+				return nil, fmt.Errorf("prolog of `%s`: %w", snippetData.Symbol, err)
+			}
+			return list, nil
 		}
 	}
 	return []dst.Stmt{
@@ -188,14 +253,20 @@ func GetProloglogBody(prologCallArgs []dst.Expr, signatrue string) []dst.Stmt {
 				},
 			},
 		},
-	}
+	}, nil
 }
 
 // Return the instrumentation statement node to be added to a function body.
-func newInstrumentationStmt(prologLoadFuncIdent string, prologCallArgs, epilogCallArgs []dst.Expr, id string, signatrue string) dst.Stmt {
+func newInstrumentationStmt(prologLoadFuncIdent string, prologCallArgs, epilogCallArgs []dst.Expr, snippetData *configs.SnippetData) (dst.Stmt, error) {
 
-	epilogBody := GetEpilogBody(epilogCallArgs, signatrue)
-	prologBody := GetProloglogBody(prologCallArgs, signatrue)
+	epilogBody, err := GetEpilogBody(epilogCallArgs, snippetData)
+	if err != nil {
+		return nil, err
+	}
+	prologBody, err := GetProloglogBody(prologCallArgs, snippetData)
+	if err != nil {
+		return nil, err
+	}
 
 	return &dst.BlockStmt{
 		List: []dst.Stmt{
@@ -241,17 +312,44 @@ func newInstrumentationStmt(prologLoadFuncIdent string, prologCallArgs, epilogCa
 				},
 			},
 		},
+	}, nil
+}
+
+// Return the data of the code snippet templates of the function, whose
+// parameters and results must already be named.
+func newSnippetData(signatrue, id string, funcDecl *dst.FuncDecl) *configs.SnippetData {
+	var params, results []*dst.Field
+	if funcDecl.Recv != nil {
+		params = append(params, funcDecl.Recv.List...)
+	}
+	params = append(params, funcDecl.Type.Params.List...)
+	if funcDecl.Type.Results != nil {
+		results = funcDecl.Type.Results.List
+	}
+	data := configs.NewSnippetData(signatrue, id, newSnippetVars(params), newSnippetVars(results))
+	data.With = configs.ConfigData.Codes[signatrue].With
+	return data
+}
+
+func newSnippetVars(fields []*dst.Field) []configs.SnippetVar {
+	var vars []configs.SnippetVar
+	for _, field := range fields {
+		typ := exprString(field.Type)
+		for _, name := range field.Names {
+			vars = append(vars, configs.SnippetVar{Name: name.Name, Type: typ})
+		}
 	}
+	return vars
 }
 
 // Return the epilog type of the given function type.
 // `f(<params>) <results>` returns `func(<*params>) (<epilog type>, error)`
-func newPrologFuncType(funcDecl *dst.FuncDecl, epilogType *dst.FuncType) (prologType *dst.FuncType, callParams []dst.Expr) {
+func newPrologFuncType(funcDecl *dst.FuncDecl, epilogType *dst.FuncType, typeParams map[string]bool) (prologType *dst.FuncType, callParams []dst.Expr) {
 	funcType := funcDecl.Type
 
 	var callbackTypeParamList *dst.FieldList
 	var callbackCallParams []dst.Expr
-	callbackTypeParamList, callbackCallParams = newCallbackParams(funcDecl.Recv, funcType.Params, "_param")
+	callbackTypeParamList, callbackCallParams = newCallbackParams(funcDecl.Recv, funcType.Params, "_param", typeParams)
 	return &dst.FuncType{
 		Params: callbackTypeParamList,
 		Results: &dst.FieldList{
@@ -269,8 +367,8 @@ func newPrologFuncType(funcDecl *dst.FuncDecl, epilogType *dst.FuncType) (prolog
 
 // Return the epilog type of the given function type.
 // `f(<params>) <results>` returns `func(<*results>)`
-func newEpilogFuncType(funcType *dst.FuncType) (epilogType *dst.FuncType, callParams []dst.Expr) {
-	callbackTypeParamList, callbackCallParams := newCallbackParams(nil, funcType.Results, "_result")
+func newEpilogFuncType(funcType *dst.FuncType, typeParams map[string]bool) (epilogType *dst.FuncType, callParams []dst.Expr) {
+	callbackTypeParamList, callbackCallParams := newCallbackParams(nil, funcType.Results, "_result", typeParams)
 	return &dst.FuncType{
 		Params:  callbackTypeParamList,
 		Results: &dst.FieldList{},
@@ -279,8 +377,9 @@ func newEpilogFuncType(funcType *dst.FuncType) (epilogType *dst.FuncType, callPa
 
 // newCallbackParams walks the given function parameters and returns the
 // parameter for the callback (prolog or epilog), along with the list of call
-// arguments.
-func newCallbackParams(recv *dst.FieldList, params *dst.FieldList, ignoredParamPrefix string) (callbackTypeParamList *dst.FieldList, callbackCallParams []dst.Expr) {
+// arguments. The parameters whose type uses the type parameters have type
+// `interface{}`.
+func newCallbackParams(recv *dst.FieldList, params *dst.FieldList, ignoredParamPrefix string, typeParams map[string]bool) (callbackTypeParamList *dst.FieldList, callbackCallParams []dst.Expr) {
 	var callbackTypeParams []*dst.Field
 	var hookedParams []*dst.Field
 	if recv != nil {
@@ -293,6 +392,9 @@ func newCallbackParams(recv *dst.FieldList, params *dst.FieldList, ignoredParamP
 	for _, hookedParam := range hookedParams {
 		var callbackTypeParam *dst.Field
 		callbackTypeParam = &dst.Field{Type: newCallbackParamType(hookedParam.Type)}
+		if usesTypeParams(hookedParam.Type, typeParams) {
+			callbackTypeParam.Type = newEmptyInterfaceType()
+		}
 		if len(hookedParam.Names) == 0 {
 			// Case where the parameter has no name such as f(string): no longer
 			// ignore it and name it.
@@ -356,7 +458,7 @@ func newParamIdent(prefix string, p int) *dst.Ident {
 
 // Return the hook descriptor function declaration which returns the hook
 // descriptor structure.
-func newHookDescriptorFuncDecl(ident string, funcDecl *dst.FuncDecl, prologVarIdent string, newDescriptorValueInitializer HookDescriptorValueInitializer) *dst.FuncDecl {
+func newHookDescriptorFuncDecl(ident string, funcValue dst.Expr, prologVarIdent string, newDescriptorValueInitializer HookDescriptorValueInitializer) *dst.FuncDecl {
 	return &dst.FuncDecl{
 		Decs: dst.FuncDeclDecorations{
 			NodeDecs: dst.NodeDecs{
@@ -387,7 +489,7 @@ func newHookDescriptorFuncDecl(ident string, funcDecl *dst.FuncDecl, prologVarId
 					},
 					Tok: token.ASSIGN,
 					Rhs: []dst.Expr{
-						newDescriptorValueInitializer(newFunctionValueExpr(funcDecl), newIdentAddressExpr(dst.NewIdent(prologVarIdent))),
+						newDescriptorValueInitializer(funcValue, newIdentAddressExpr(dst.NewIdent(prologVarIdent))),
 					},
 				},
 			},
@@ -464,31 +566,127 @@ func NewHookDescriptorType() (*dst.GenDecl, *dst.TypeSpec, HookDescriptorValueIn
 }
 
 func ShouldIgnoreFuncDecl(funcDecl *dst.FuncDecl) bool {
+	return FuncDeclIgnoredReason(funcDecl) != ""
+}
+
+// FuncDeclIgnoredReason returns the reason why the function declaration must
+// not be instrumented, or an empty string when it can be.
+func FuncDeclIgnoredReason(funcDecl *dst.FuncDecl) string {
 	fname := funcDecl.Name.Name
 	// don't instrument:
+	// - functions without body (eg. implemented in assembly).
 	// - `_`: explicitly ignored function names.
-	// - `init`: package init functions.
+	// - `init`: package init functions, unless configured (see
+	//   configs.IsInitHookpoint), and methods named `init`.
 	// - `.*noescape.*`: any function name containing `noescape` since we would
 	//    likely break it.
 	// - functions having //go:nosplit directives because they are usually low-level
 	//   functions.
 	// - functions having //autobuild:ignore directives.
-	return funcDecl.Body == nil ||
-		fname == "_" ||
-		fname == "init" ||
-		strings.Contains(fname, "noescape") ||
-		HasIgnoreDirective(funcDecl) ||
-		hasGoNoSplitDirective(funcDecl)
+	switch {
+	case funcDecl.Body == nil:
+		return "no body"
+	case fname == "_":
+		return "blank name"
+	case strings.Contains(fname, "noescape"):
+		return "noescape"
+	case HasIgnoreDirective(funcDecl):
+		return "ignore directive"
+	case hasGoNoSplitDirective(funcDecl):
+		return "nosplit"
+	case fname == configs.InitFuncName:
+		// Last so that the other reasons apply to the configured init
+		// functions.
+		return InitIgnoredReason
+	}
+	return ""
+}
+
+// InitIgnoredReason is the reason why package init functions are ignored
+// when not configured.
+const InitIgnoredReason = "init"
+
+// IsInitFuncDecl returns true when the function declaration is a package
+// init function.
+func IsInitFuncDecl(funcDecl *dst.FuncDecl) bool {
+	return funcDecl.Recv == nil && funcDecl.Name.Name == configs.InitFuncName
 }
 
 func IsHookDescriptorFuncInMainPackage(ident string) bool {
-	return strings.HasPrefix(ident, configs.HookDescriptorFuncIdentPrefixOfMainPackage)
+	return strings.HasPrefix(ident, configs.HookDescriptorFuncIdentPrefixOfMainPackage) ||
+		configs.IsCallsiteInMainPackage(strings.TrimPrefix(ident, configs.HookDescriptorIdentPrefix))
+}
+
+// newKindInstrumentation returns the instrumentation statement of the built-in
+// hook kind, followed by the given prolog instrumentation statement unless the
+// kind returns, along with its package-level declarations.
+func newKindInstrumentation(name string, kind configs.HookKind, snippetData *configs.SnippetData, prologStmt dst.Stmt) (dst.Stmt, []dst.Decl, error) {
+	list, err := GetSnippetAst(name, kind.Code, snippetData)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !kind.Returns {
+		list = append(list, prologStmt)
+	}
+	var decls []dst.Decl
+	if kind.Decls != "" {
+		code, err := configs.RenderSnippet(name, kind.Decls, snippetData)
+		if err != nil {
+			return nil, nil, err
+		}
+		file, err := decorator.Parse("package a\n" + code)
+		if err != nil {
+			return nil, nil, err
+		}
+		decls = file.Decls
+	}
+	return &dst.BlockStmt{List: list}, decls, nil
+}
+
+// renameSnippetImports renames the package names of the selector expressions
+// of the code snippets into the names the packages are imported with, which
+// cannot conflict with the names of the instrumented file.
+func renameSnippetImports(node dst.Node, specs configs.StringList) ([]configs.SnippetImport, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	var imports []configs.SnippetImport
+	names := make(map[string]configs.SnippetImport, len(specs))
+	for _, spec := range specs {
+		imp, err := configs.ParseSnippetImport(spec)
+		if err != nil {
+			return nil, err
+		}
+		names[imp.Name] = imp
+		imports = append(imports, imp)
+	}
+	dst.Inspect(node, func(n dst.Node) bool {
+		if sel, ok := n.(*dst.SelectorExpr); ok {
+			if x, ok := sel.X.(*dst.Ident); ok {
+				if imp, ok := names[x.Name]; ok {
+					x.Name = imp.Ident()
+				}
+			}
+		}
+		return true
+	})
+	return imports, nil
+}
+
+// GetSnippetAst renders the code snippet template and returns its statements.
+func GetSnippetAst(name, snippet string, data *configs.SnippetData) ([]dst.Stmt, error) {
+	code, err := configs.RenderSnippet(name, snippet, data)
+	if err != nil {
+		return nil, err
+	}
+	return GetBlockAst(code)
 }
 
-func GetBlockAst(data string) []dst.Stmt {
+func GetBlockAst(data string) ([]dst.Stmt, error) {
 	file, err := decorator.Parse(fmt.Sprintf(configs.CodeTemplate, data))
 	if err != nil {
 		log.Printf("decorator.Parse failed, parse `%s`, err %s", data, err.Error())
+		return nil, err
 	}
-	return file.Decls[0].(*dst.FuncDecl).Body.List
+	return file.Decls[0].(*dst.FuncDecl).Body.List, nil
 }
@@ -2,6 +2,7 @@ package ast
 
 import (
 	"fmt"
+	goast "go/ast"
 	"go/printer"
 	"go/token"
 	"io"
@@ -92,6 +93,93 @@ func newMethodValueExpr(fn *dst.FuncDecl) dst.Expr {
 	return newSelectorExpr(&dst.ParenExpr{X: t}, fn.Name.Name)
 }
 
+// ReceiverType returns the name of the receiver base type of the method
+// declaration, whether the receiver is a pointer and whether the type is
+// generic, eg. `List`, true, true for `func (l *List[T]) Push(v T)`.
+func ReceiverType(fn *dst.FuncDecl) (name string, pointer, generic bool) {
+	t := fn.Recv.List[0].Type
+	if star, ok := t.(*dst.StarExpr); ok {
+		pointer = true
+		t = star.X
+	}
+	for {
+		switch actual := t.(type) {
+		case *dst.ParenExpr:
+			t = actual.X
+		case *dst.IndexExpr:
+			generic = true
+			t = actual.X
+		case *dst.IndexListExpr:
+			generic = true
+			t = actual.X
+		case *dst.Ident:
+			return actual.Name, pointer, generic
+		default:
+			return "", pointer, generic
+		}
+	}
+}
+
+// IsGenericFuncDecl returns true when the function declaration has type
+// parameters or is a method of a generic type.
+func IsGenericFuncDecl(fn *dst.FuncDecl) bool {
+	return len(typeParamNames(fn)) > 0
+}
+
+// typeParamNames returns the names of the type parameters of the function or
+// of the receiver type of the method.
+func typeParamNames(fn *dst.FuncDecl) map[string]bool {
+	names := make(map[string]bool)
+	if fn.Type.TypeParams != nil {
+		for _, field := range fn.Type.TypeParams.List {
+			for _, name := range field.Names {
+				names[name.Name] = true
+			}
+		}
+	}
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return names
+	}
+	t := fn.Recv.List[0].Type
+	if star, ok := t.(*dst.StarExpr); ok {
+		t = star.X
+	}
+	var indices []dst.Expr
+	switch actual := t.(type) {
+	case *dst.IndexExpr:
+		indices = []dst.Expr{actual.Index}
+	case *dst.IndexListExpr:
+		indices = actual.Indices
+	}
+	for _, index := range indices {
+		if ident, ok := index.(*dst.Ident); ok {
+			names[ident.Name] = true
+		}
+	}
+	return names
+}
+
+// usesTypeParams returns true when the type expression references one of the
+// given type parameters.
+func usesTypeParams(typ dst.Expr, typeParams map[string]bool) (uses bool) {
+	if len(typeParams) == 0 {
+		return false
+	}
+	dst.Inspect(typ, func(node dst.Node) bool {
+		switch node := node.(type) {
+		case *dst.SelectorExpr:
+			// Qualified identifiers of other packages
+			return false
+		case *dst.Ident:
+			if typeParams[node.Name] {
+				uses = true
+			}
+		}
+		return !uses
+	})
+	return uses
+}
+
 // Return the value expression for the given function declaration.
 // It can be either a method or a function value.
 func newFunctionValueExpr(fn *dst.FuncDecl) (v dst.Expr) {
@@ -124,12 +212,55 @@ func AddUnsafePackageImport(file *dst.File) {
 	addNamedImport(file, configs.UnsafePackageName, "unsafe")
 }
 
+// AddSnippetImports adds the imports of the code snippets of the hookpoints to
+// the file, once per package.
+func AddSnippetImports(file *dst.File, hookpoints []*Hookpoint) {
+	added := make(map[string]bool)
+	for _, h := range hookpoints {
+		for _, imp := range h.Imports {
+			// Blank imports share the same name
+			if key := imp.Ident() + " " + imp.Path; !added[key] {
+				added[key] = true
+				addNamedImport(file, imp.Ident(), imp.Path)
+			}
+		}
+	}
+}
+
 func WriteFile(file *dst.File, w io.Writer) error {
 	fset, af, err := decorator.RestoreFile(file)
 	if err != nil {
 		return err
 	}
-	return printer.Fprint(w, fset, af)
+	// Same printer configuration as gofmt so that the instrumented sources only
+	// differ from the original ones by the instrumentation.
+	cfg := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+	return cfg.Fprint(w, fset, af)
+}
+
+// Return the source code of the expression.
+func exprString(expr dst.Expr) string {
+	file := &dst.File{
+		Name: dst.NewIdent("a"),
+		Decls: []dst.Decl{
+			&dst.GenDecl{
+				Tok: token.VAR,
+				Specs: []dst.Spec{
+					&dst.ValueSpec{
+						Names: []*dst.Ident{dst.NewIdent("_")},
+						Type:  dst.Clone(expr).(dst.Expr),
+					},
+				},
+			},
+		},
+	}
+	fset, af, err := decorator.RestoreFile(file)
+	if err != nil {
+		return ""
+	}
+	var typ strings.Builder
+	printer.Fprint(&typ, fset, af.Decls[0].(*goast.GenDecl).Specs[0].(*goast.ValueSpec).Type)
+	return typ.String()
 }
 
 func hasGoNoSplitDirective(funcDecl *dst.FuncDecl) bool {
@@ -4,9 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 
+	"github.com/ListenOcean/goHookTool/configs"
 	"github.com/ListenOcean/goHookTool/internal/toolexec/flags"
 	"github.com/ListenOcean/goHookTool/internal/toolexec/instrument"
 )
@@ -29,7 +32,9 @@ func parseCompileCommand(args []string) (commandExecutionFunc, error) {
 		return nil, errors.New("unexpected number of command arguments")
 	}
 	flagset := &compileFlagSet{}
-	flags.ParseFlags(flagset, args[1:])
+	if err := flags.ParseFlags(flagset, args[1:]); err != nil {
+		return nil, err
+	}
 	return makeCompileCommandExecutionFunc(flagset, args), nil
 }
 
@@ -46,24 +51,46 @@ func makeCompileCommandExecutionFunc(flags *compileFlagSet, args []string) comma
 		pkgPath := flags.Package
 		packageBuildDir := filepath.Dir(flags.Output)
 
-		var i instrument.Instrumenter
-		switch pkgPath {
-		case "runtime":
-			i = instrument.NewRuntimePackageInstrumentation(pkgPath, globalFlags.Full, packageBuildDir)
-		case "main":
-			i = instrument.NewMainPackageInstrumentation(pkgPath, globalFlags.Full, packageBuildDir)
-		default:
-			i = instrument.NewDefaultPackageInstrumentation(pkgPath, globalFlags.Full, packageBuildDir)
+		i := instrument.NewPackageInstrumentation(pkgPath, globalFlags.Full, packageBuildDir)
+		instrument.Strict = globalFlags.Strict || (configs.ConfigData.Strict != nil && *configs.ConfigData.Strict)
+		instrument.ReportFilepath = globalFlags.Report
+		if pkgPath == "main" {
+			// eg. `pkg [pkg.test]` for test variants of the package
+			instrument.MainImportPath, _, _ = strings.Cut(os.Getenv("TOOLEXEC_IMPORTPATH"), " ")
 		}
 
 		if i.IsIgnored() {
 			log.Printf("skipping instrumentation of package `%s`\n", pkgPath)
 			return nil, nil
 		}
+		if pkgPath == "runtime" {
+			goVersion, err := compilerGoVersion(args[0])
+			if err != nil {
+				return nil, err
+			}
+			log.Printf("compiler go version `%s`\n", goVersion)
+			instrument.GoVersion = goVersion
+		}
 		return Instrument(i, args, pkgPath, packageBuildDir)
 	}
 }
 
+// compilerGoVersion returns the Go version of the compile tool, given by its
+// `-V=full` output, eg. `compile version go1.21.3` or
+// `compile version devel go1.23-c1e48a8 Wed Feb 7 18:27:01 2024 +0000`.
+func compilerGoVersion(compile string) (string, error) {
+	out, err := exec.Command(compile, "-V=full").Output()
+	if err != nil {
+		return "", fmt.Errorf("get the compiler version: %w", err)
+	}
+	for _, field := range strings.Fields(string(out)) {
+		if _, ok := configs.ParseGoVersion(field); ok {
+			return field, nil
+		}
+	}
+	return "", fmt.Errorf("unexpected compiler version `%s`", strings.TrimSpace(string(out)))
+}
+
 // Update the argument list by replacing source files that were instrumented.
 func updateArgs(args []string, argIndices map[string]int, written map[string]string) {
 	for src, dest := range written {
@@ -102,16 +129,45 @@ func Instrument(i instrument.Instrumenter, args []string, pkgPath, packageBuildD
 		}
 	}
 
-	if instrumented, err := i.Instrument(); err != nil {
+	if configs.HasCallsites() || configs.HasInterfaces() {
+		files, err := importcfgPackageFiles(args)
+		if err != nil {
+			return nil, err
+		}
+		instrument.ImportcfgFiles = files
+	}
+
+	instrumented, err := i.Instrument()
+	if err != nil {
 		return nil, err
-	} else if len(instrumented) > 0 {
-		written, err := i.WriteInstrumentedFiles(packageBuildDir, instrumented)
+	}
+	if instrument.Strict {
+		if unmatched := instrument.UnmatchedHookpoints(i); len(unmatched) > 0 {
+			return nil, &instrument.UnmatchedHookpointsError{PkgPath: pkgPath, Hookpoints: unmatched}
+		}
+	}
+	if len(instrumented) > 0 {
+		outputDir, err := instrumentedFilesDir(pkgPath, packageBuildDir)
+		if err != nil {
+			return nil, err
+		}
+		written, err := i.WriteInstrumentedFiles(outputDir, instrumented)
 		if err != nil {
 			return nil, err
 		}
 		// Replace original files in the args by the new ones
 		updateArgs(args, argIndices, written)
 	}
+	if imports := instrument.SnippetImportPaths(i); len(imports) > 0 {
+		if err := extendCompileImportcfg(args, imports, packageBuildDir); err != nil {
+			return nil, err
+		}
+	}
+
+	// Also used by the main package to check the hookpoint patterns.
+	if err := instrument.AppendPackageReport(i, packageBuildDir); err != nil {
+		return nil, err
+	}
 
 	extraFiles, err := i.WriteExtraFiles()
 	if err != nil {
@@ -121,3 +177,38 @@ func Instrument(i instrument.Instrumenter, args []string, pkgPath, packageBuildD
 	args = append(args, extraFiles...)
 	return args, nil
 }
+
+// instrumentedFilesDir returns the directory where the instrumented files are
+// written: the package build directory, or the package directory in the
+// directory of kept instrumented files.
+func instrumentedFilesDir(pkgPath, packageBuildDir string) (string, error) {
+	switch globalFlags.LineDirectives {
+	case "", instrument.LineDirectivesOriginal:
+		instrument.LineDirectives = instrument.LineDirectivesOriginal
+	case instrument.LineDirectivesInstrumented:
+		if globalFlags.KeepInstrumented == "" {
+			// The build directory is trimmed from the file names by the compiler.
+			return "", errors.New("-line-directives=instrumented requires -keep-instrumented")
+		}
+		instrument.LineDirectives = instrument.LineDirectivesInstrumented
+	default:
+		return "", fmt.Errorf("unexpected -line-directives value `%s`", globalFlags.LineDirectives)
+	}
+
+	if globalFlags.KeepInstrumented == "" {
+		return packageBuildDir, nil
+	}
+	// Prefer the import path set by the go command since the compiler package
+	// path of every main package is `main`.
+	importPath := pkgPath
+	if env := os.Getenv("TOOLEXEC_IMPORTPATH"); env != "" {
+		// eg. `pkg [pkg.test]` for test variants of the package
+		importPath = strings.NewReplacer(" [", "@", "]", "").Replace(env)
+	}
+	dir := filepath.Join(globalFlags.KeepInstrumented, filepath.FromSlash(instrument.UnvendorPackagePath(importPath)))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	log.Println("keeping instrumented files into", dir)
+	return dir, nil
+}
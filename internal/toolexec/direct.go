@@ -0,0 +1,87 @@
+package toolexec
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ListenOcean/goHookTool/configs"
+	"github.com/ListenOcean/goHookTool/utils"
+)
+
+// DirectInvocationArgs returns the autobuild arguments of a direct toolexec
+// invocation by the go command, ie. with `GOFLAGS=-toolexec=autobuild`: the
+// first argument is then the absolute path of the go tool, and the toolexec
+// options are given by CUSTOMTOOLEXECFLAGS.
+func DirectInvocationArgs(args []string) ([]string, bool) {
+	if len(args) == 0 || !filepath.IsAbs(args[0]) {
+		return nil, false
+	}
+	options, err := utils.SplitQuoted(os.Getenv(configs.TagCustomToolexecFlags))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "autobuild: $%s: %v\n", configs.TagCustomToolexecFlags, err)
+		os.Exit(2)
+	}
+	return append(append([]string{"toolexec"}, options...), args...), true
+}
+
+// isToolVersionQuery returns true when the go command asks for the tool
+// version to compute its build cache keys.
+func isToolVersionQuery(args []string) bool {
+	return len(args) == 2 && args[1] == "-V=full"
+}
+
+// printToolVersion prints the tool version with an autobuild identifier of the
+// instrumentation, so that the go build cache never mixes instrumented and
+// regular packages, nor packages instrumented with different configs.
+func printToolVersion(args []string, options []string) error {
+	out, err := exec.Command(args[0], args[1:]...).Output()
+	if err != nil {
+		return err
+	}
+	id, err := instrumentationID(options)
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(out))
+	// Development versions are identified by their trailing build ID, which
+	// must stay the last field.
+	last := len(fields)
+	if last > 0 && strings.HasPrefix(fields[last-1], "buildID=") {
+		last--
+	}
+	fields = append(fields[:last], append([]string{"autobuild=" + id}, fields[last:]...)...)
+	_, err = fmt.Println(strings.Join(fields, " "))
+	return err
+}
+
+// instrumentationID hashes what the instrumentation depends on: the autobuild
+// executable, the toolexec options and the resolved config.
+func instrumentationID(options []string) (string, error) {
+	h := sha256.New()
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(executable)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(h, "%q\n", options)
+	config, err := json.Marshal(configs.ConfigData)
+	if err != nil {
+		return "", err
+	}
+	h.Write(config)
+	return hex.EncodeToString(h.Sum(nil))[:16], nil
+}
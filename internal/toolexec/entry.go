@@ -16,7 +16,6 @@ import (
 	"github.com/ListenOcean/goHookTool/internal/toolexec/flags"
 
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
 )
 
 var globalFlags flags.InstrumentationToolFlagSet
@@ -49,6 +48,15 @@ func CheckAndCleanLogFile(filename string) {
 	}
 }
 
+// exitError logs err and exits with the given code. The error is also written
+// to stderr, which the go command shows under the name of the package being
+// built.
+func exitError(err error, code int) {
+	log.Println(err)
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(code)
+}
+
 func ToolexecEntry(cobracmd *cobra.Command, args []string) {
 	log.SetOutput(os.Stdout)
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile | log.Lmsgprefix)
@@ -66,35 +74,50 @@ func ToolexecEntry(cobracmd *cobra.Command, args []string) {
 	log.Printf("\n\nToolexec Start\n")
 
 	cmd, cmdArgPos, err := parseCommand(&globalFlags, args)
-	if err != nil || globalFlags.Help {
-		log.Println(err)
+	if err != nil {
+		exitError(err, 2)
+	}
+	if globalFlags.Help {
 		printUsage()
-		os.Exit(1)
 	}
 
 	// Hide instrumentation tool arguments
+	var options []string
 	if cmdArgPos != -1 {
+		options = args[:cmdArgPos]
 		args = args[cmdArgPos:]
 	}
+	toolexecOptions = options
 
 	// var logs strings.Builder
 	if !globalFlags.Verbose {
 		// Save the logs to show them in case of instrumentation error
 		log.SetOutput(logFile)
+	} else {
+		// Logged along with the errors, see exitError
+		log.SetOutput(io.MultiWriter(logFile, os.Stderr))
 	}
 
 	// 读取Hook点配置
-	if err := ReadConfig(); err != nil {
+	target := configs.TargetFromEnv(splitTags(globalFlags.Tags))
+	if err := configs.ReadConfig(globalFlags.Config, target); errors.Is(err, configs.ErrNoConfig) {
 		log.Println("Found no config, maybe no hookpoints")
+	} else if err != nil {
+		exitError(fmt.Errorf("read config: %v", err), 1)
 	}
 
 	log.Printf("origin command \"%s\"", strings.Join(args, "\", \""))
+	if isToolVersionQuery(args) {
+		if err := printToolVersion(args, options); err != nil {
+			exitError(err, 1)
+		}
+		os.Exit(0)
+	}
 	if cmd != nil {
 		// The command is implemented
 		newArgs, err := cmd()
 		if err != nil {
-			log.Println(err)
-			os.Exit(1)
+			exitError(err, 1)
 		}
 		if newArgs != nil {
 			// Args are replaced
@@ -115,29 +138,6 @@ func ToolexecEntry(cobracmd *cobra.Command, args []string) {
 	os.Exit(0)
 }
 
-func ReadConfig() error {
-	configFile := os.Getenv(configs.TagCustomConfig)
-	if configFile == "" {
-		return errors.New("no config file")
-	}
-	datas, err := os.ReadFile(configFile)
-	if err != nil {
-		return err
-	}
-	err = yaml.Unmarshal(datas, &configs.ConfigData)
-	if err != nil {
-		return err
-	}
-	// convert to HookPointMap
-	for key, values := range configs.ConfigData.Hookpoints {
-		configs.HookPointMap[key] = make(map[string]struct{})
-		for _, value := range values {
-			configs.HookPointMap[key][value] = struct{}{}
-		}
-	}
-	return nil
-}
-
 // forwardCommand runs the given command's argument list and exits the process
 // with the exit code that was returned.
 func forwardCommand(args []string) error {
@@ -160,6 +160,13 @@ func forwardCommand(args []string) error {
 	return err
 }
 
+func splitTags(tags string) []string {
+	if tags == "" {
+		return nil
+	}
+	return strings.Split(tags, ",")
+}
+
 func printUsage() {
 	const usageFormat = `Nothing yd`
 	_, _ = fmt.Fprintf(os.Stderr, usageFormat)
@@ -171,18 +178,22 @@ type commandExecutionFunc func() (newArgs []string, err error)
 
 var commandParserMap = map[string]parseCommandFunc{
 	"compile": parseCompileCommand,
+	"link":    parseLinkCommand,
 }
 
 // getCommand returns the command and arguments. The command is expectedFlags to be
 // the first argument.
 func parseCommand(instrToolFlagSet *flags.InstrumentationToolFlagSet, args []string) (commandExecutionFunc, int, error) {
-	cmdIdPos := flags.ParseFlagsUntilFirstNonOptionArg(instrToolFlagSet, args)
+	cmdIdPos, err := flags.ParseFlagsUntilFirstNonOptionArg(instrToolFlagSet, args)
+	if err != nil {
+		return nil, cmdIdPos, err
+	}
 	if cmdIdPos == -1 {
 		return nil, cmdIdPos, errors.New("unexpected arguments")
 	}
 	cmdId := args[cmdIdPos]
 	args = args[cmdIdPos:]
-	cmdId, err := parseCommandID(cmdId)
+	cmdId, err = parseCommandID(cmdId)
 	if err != nil {
 		return nil, cmdIdPos, err
 	}
@@ -1,7 +1,9 @@
 package flags
 
 import (
+	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 )
 
@@ -9,6 +11,18 @@ type InstrumentationToolFlagSet struct {
 	Help    bool `sqflag:"-h"`
 	Verbose bool `sqflag:"-v"`
 	Full    bool `sqflag:"-full"`
+	// Hook configuration file, CUSTOMCONFIG when empty.
+	Config string `sqflag:"-config"`
+	// Comma-separated build tags, for the config section conditions.
+	Tags string `sqflag:"-tags"`
+	// Fail the build when configured hookpoints are not instrumented.
+	Strict bool `sqflag:"-strict"`
+	// Path of the JSON instrumentation report written at the end of the build.
+	Report string `sqflag:"-report"`
+	// Directory where the instrumented files are kept, mirroring package paths.
+	KeepInstrumented string `sqflag:"-keep-instrumented"`
+	// Line directive mode of the instrumented files: original or instrumented.
+	LineDirectives string `sqflag:"-line-directives"`
 }
 
 const structTagKey = "sqflag"
@@ -16,18 +30,24 @@ const structTagKey = "sqflag"
 // parseFlags walks through the given arguments and sets the flagSet values
 // present in the argument list. Unknown options, not present in the flagSet
 // are accepted and skipped. The argument list is not modified.
-func ParseFlags(flagSet interface{}, args []string) {
+func ParseFlags(flagSet interface{}, args []string) error {
 	flagSetValueMap := makeFlagSetValueMap(flagSet)
 
 	i := 0
 	for i < len(args)-1 {
-		_, shift := parseOption(flagSetValueMap, args[i], args[i+1])
+		_, shift, err := parseOption(flagSetValueMap, args[i], args[i+1])
+		if err != nil {
+			return err
+		}
 		i += shift
 	}
 
 	if i < len(args) {
-		_, _ = parseOption(flagSetValueMap, args[i], "")
+		if _, _, err := parseOption(flagSetValueMap, args[i], ""); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
 func makeFlagSetValueMap(flagSet interface{}) map[string]reflect.Value {
@@ -45,10 +65,10 @@ func makeFlagSetValueMap(flagSet interface{}) map[string]reflect.Value {
 
 // parseOption parses the given current argument and following one according to
 // the go flags syntax.
-func parseOption(flagSetValueMap map[string]reflect.Value, arg, nextArg string) (nonOpt bool, shift int) {
+func parseOption(flagSetValueMap map[string]reflect.Value, arg, nextArg string) (nonOpt bool, shift int, err error) {
 	if arg[0] != '-' {
 		// Not an option, return the value and shift by one.
-		return true, 1
+		return true, 1, nil
 	}
 
 	// Split the argument by its first `=` character if any, and check the
@@ -63,7 +83,16 @@ func parseOption(flagSetValueMap map[string]reflect.Value, arg, nextArg string)
 		value := kv[1]
 		shift = 1
 		if exists {
-			flag.SetString(value)
+			switch flag.Kind() {
+			case reflect.String:
+				flag.SetString(value)
+			case reflect.Bool:
+				b, err := strconv.ParseBool(value)
+				if err != nil {
+					return false, shift, fmt.Errorf("invalid boolean value %q for %s", value, option)
+				}
+				flag.SetBool(b)
+			}
 		}
 	} else if nextArg == "" || len(nextArg) > 1 && nextArg[0] != '-' {
 		// `-opt val` syntax
@@ -89,30 +118,36 @@ func parseOption(flagSetValueMap map[string]reflect.Value, arg, nextArg string)
 	return
 }
 
-func ParseFlagsUntilFirstNonOptionArg(flagSet interface{}, args []string) int {
+func ParseFlagsUntilFirstNonOptionArg(flagSet interface{}, args []string) (int, error) {
 	if len(args) == 0 {
-		return -1
+		return -1, nil
 	}
 
 	flagSetValueMap := makeFlagSetValueMap(flagSet)
 
 	i := 0
 	for i < len(args)-1 {
-		nonOpt, shift := parseOption(flagSetValueMap, args[i], args[i+1])
+		nonOpt, shift, err := parseOption(flagSetValueMap, args[i], args[i+1])
+		if err != nil {
+			return -1, err
+		}
 		if nonOpt {
 			// First non-option
-			return i
+			return i, nil
 		}
 		i += shift
 	}
 
 	if i < len(args) {
-		nonOpt, _ := parseOption(flagSetValueMap, args[i], "")
+		nonOpt, _, err := parseOption(flagSetValueMap, args[i], "")
+		if err != nil {
+			return -1, err
+		}
 		if nonOpt {
 			// First non-option
-			return i
+			return i, nil
 		}
 	}
 
-	return -1
+	return -1, nil
 }
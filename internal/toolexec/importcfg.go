@@ -0,0 +1,271 @@
+package toolexec
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ListenOcean/goHookTool/configs"
+	"github.com/ListenOcean/goHookTool/internal/toolexec/instrument"
+	"github.com/ListenOcean/goHookTool/utils"
+)
+
+// Name of the file, in the build work directory, listing the packages added
+// to the compile importcfg files, to add them to the link importcfg file too.
+const linkImportcfgFileName = "importcfg.autobuild.link"
+
+// toolexecOptions are the options autobuild's toolexec command was run with,
+// also used to build the packages the code snippets import.
+var toolexecOptions []string
+
+// importcfgArg returns the index of the importcfg file argument of the
+// compile or link command, -1 when there is none.
+func importcfgArg(args []string) int {
+	for i, arg := range args {
+		switch {
+		case arg == "-importcfg" && i+1 < len(args):
+			return i + 1
+		case strings.HasPrefix(arg, "-importcfg="):
+			return i
+		}
+	}
+	return -1
+}
+
+func replaceImportcfgArg(args []string, i int, importcfg string) {
+	if strings.HasPrefix(args[i], "-importcfg=") {
+		args[i] = "-importcfg=" + importcfg
+	} else {
+		args[i] = importcfg
+	}
+}
+
+func importcfgArgValue(arg string) string {
+	return strings.TrimPrefix(arg, "-importcfg=")
+}
+
+// readImportcfg returns the content of the importcfg file and the import
+// paths it resolves.
+func readImportcfg(importcfg string) ([]byte, map[string]bool, error) {
+	data, err := os.ReadFile(importcfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, importcfgPackages(data), nil
+}
+
+// importcfgPackages returns the import paths of the `packagefile` and
+// `importmap` lines of the importcfg content.
+func importcfgPackages(data []byte) map[string]bool {
+	packages := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		verb, args, _ := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		if verb != "packagefile" && verb != "importmap" {
+			continue
+		}
+		if path, _, ok := strings.Cut(args, "="); ok {
+			packages[path] = true
+		}
+	}
+	return packages
+}
+
+// extendCompileImportcfg adds the packages imported by the code snippets, and
+// their dependencies, to the importcfg file of the compile command when they
+// are not already dependencies of the compiled package. They are also
+// recorded for the link command.
+func extendCompileImportcfg(args []string, imports []string, packageBuildDir string) error {
+	i := importcfgArg(args)
+	if i == -1 {
+		return nil
+	}
+	data, packages, err := readImportcfg(importcfgArgValue(args[i]))
+	if err != nil {
+		return err
+	}
+	var missing []string
+	for _, path := range imports {
+		if !packages[path] {
+			missing = append(missing, path)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	log.Printf("building the packages imported by the code snippets: %s", strings.Join(missing, " "))
+	workDir := instrument.ProjectBuildDir(packageBuildDir)
+	lines, err := exportPackageFiles(args[0], missing, workDir)
+	if err != nil {
+		return err
+	}
+	added := appendImportcfgLines(&data, packages, lines)
+	importcfg := filepath.Join(packageBuildDir, "importcfg.autobuild")
+	if err := os.WriteFile(importcfg, data, 0644); err != nil {
+		return err
+	}
+	replaceImportcfgArg(args, i, importcfg)
+
+	f, err := os.OpenFile(filepath.Join(workDir, linkImportcfgFileName), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString(strings.Join(added, ""))
+	return err
+}
+
+// importcfgPackageFiles returns the export data files of the import paths of
+// the importcfg file of the compile command, nil when there is none.
+func importcfgPackageFiles(args []string) (map[string]string, error) {
+	i := importcfgArg(args)
+	if i == -1 {
+		return nil, nil
+	}
+	data, err := os.ReadFile(importcfgArgValue(args[i]))
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]string)
+	importmap := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		verb, args, _ := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		path, value, ok := strings.Cut(args, "=")
+		if !ok {
+			continue
+		}
+		switch verb {
+		case "packagefile":
+			files[path] = value
+		case "importmap":
+			importmap[path] = value
+		}
+	}
+	for path, actual := range importmap {
+		if file, ok := files[actual]; ok {
+			files[path] = file
+		}
+	}
+	return files, nil
+}
+
+// appendImportcfgLines appends the lines of the packages missing from the
+// importcfg content and returns them.
+func appendImportcfgLines(data *[]byte, packages map[string]bool, lines []string) (added []string) {
+	if len(*data) > 0 && (*data)[len(*data)-1] != '\n' {
+		*data = append(*data, '\n')
+	}
+	for _, line := range lines {
+		path, _, _ := strings.Cut(strings.TrimPrefix(line, "packagefile "), "=")
+		if packages[path] {
+			continue
+		}
+		packages[path] = true
+		line += "\n"
+		*data = append(*data, line...)
+		added = append(added, line)
+	}
+	return added
+}
+
+// exportPackageFiles builds the packages and their dependencies with the same
+// instrumentation and go build flags, so that they match the packages of the
+// build, and returns their importcfg `packagefile` lines. Their hooks and
+// reports are added to the ones of the build work directory.
+func exportPackageFiles(tool string, paths []string, workDir string) ([]string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	buildFlags, err := utils.SplitQuoted(os.Getenv(configs.TagCustomGoBuildFlags))
+	if err != nil {
+		return nil, fmt.Errorf("$%s: %w", configs.TagCustomGoBuildFlags, err)
+	}
+	toolexec, err := utils.JoinQuoted(append([]string{exe, "toolexec"}, toolexecOptions...))
+	if err != nil {
+		return nil, err
+	}
+	// -a: the packages must be compiled, rather than found in the build cache,
+	// to list their hooks.
+	args := []string{
+		"list", "-export", "-deps", "-a",
+		"-toolexec=" + toolexec,
+		"-f", "{{if .Export}}packagefile {{.ImportPath}}={{.Export}}{{end}}",
+	}
+	if globalFlags.Tags != "" {
+		args = append(args, "-tags="+globalFlags.Tags)
+	}
+	args = append(args, buildFlags...)
+	args = append(args, paths...)
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(goCommand(tool), args...)
+	cmd.Env = append(os.Environ(), configs.TagCustomWorkDir+"="+workDir)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("build the packages imported by the code snippets: %w\n%s", err, stderr.String())
+	}
+	var lines []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// goCommand returns the go command of the toolchain of the given go tool, eg.
+// `$GOROOT/pkg/tool/linux_amd64/compile`.
+func goCommand(tool string) string {
+	goroot := filepath.Dir(filepath.Dir(filepath.Dir(filepath.Dir(tool))))
+	return filepath.Join(goroot, "bin", "go")
+}
+
+func parseLinkCommand(args []string) (commandExecutionFunc, error) {
+	if len(args) == 0 {
+		return nil, errors.New("unexpected number of command arguments")
+	}
+	return func() ([]string, error) {
+		return extendLinkImportcfg(args)
+	}, nil
+}
+
+// extendLinkImportcfg adds the packages added to the compile importcfg files
+// to the importcfg file of the link command.
+func extendLinkImportcfg(args []string) ([]string, error) {
+	i := importcfgArg(args)
+	if i == -1 {
+		return nil, nil
+	}
+	importcfg := importcfgArgValue(args[i])
+	// eg. $WORK/b001/importcfg.link
+	added, err := os.ReadFile(filepath.Join(filepath.Dir(importcfg), "..", linkImportcfgFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	data, packages, err := readImportcfg(importcfg)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSpace(string(added)), "\n")
+	if len(appendImportcfgLines(&data, packages, lines)) == 0 {
+		return nil, nil
+	}
+	extended := filepath.Join(filepath.Dir(importcfg), "importcfg.autobuild.link")
+	if err := os.WriteFile(extended, data, 0644); err != nil {
+		return nil, err
+	}
+	replaceImportcfgArg(args, i, extended)
+	return args, nil
+}
@@ -0,0 +1,430 @@
+package instrument
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"go/types"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/ListenOcean/goHookTool/configs"
+	"github.com/ListenOcean/goHookTool/internal/toolexec/ast"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/dave/dst/dstutil"
+)
+
+// callsiteInstrumentation rewrites the references to the functions configured
+// in `callsites` into references to wrapper functions, generated once per
+// package and instrumented like any other function.
+type callsiteInstrumentation struct {
+	pkgPath  string
+	importer types.Importer
+	// Wrappers of the package, by callsite signature. Nil when the function
+	// cannot be wrapped.
+	wrappers map[string]*callsiteWrapper
+	// Wrappers in creation order, so that they are declared in a stable order.
+	created []*callsiteWrapper
+	// The hook descriptor value initializer of the package.
+	newHookDescriptorValueInitializer ast.HookDescriptorValueInitializer
+}
+
+type callsiteWrapper struct {
+	funcDecl *dst.FuncDecl
+	hook     *ast.Hookpoint
+	// False until the wrapper declaration gets added to a file.
+	declared bool
+}
+
+func newCallsiteInstrumentation(pkgPath string, newHookDescriptorValueInitializer ast.HookDescriptorValueInitializer) *callsiteInstrumentation {
+	return &callsiteInstrumentation{
+		pkgPath:                           pkgPath,
+		importer:                          newImportcfgImporter(),
+		wrappers:                          make(map[string]*callsiteWrapper),
+		newHookDescriptorValueInitializer: newHookDescriptorValueInitializer,
+	}
+}
+
+// rewriteFile replaces the references to the callsite functions of the file
+// by references to their wrappers. It returns true when the file was
+// modified.
+func (c *callsiteInstrumentation) rewriteFile(file *dst.File) (rewritten bool, err error) {
+	// Names of the imported packages having callsite functions
+	imports := make(map[string]string)
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil || configs.CallsiteMap[path] == nil {
+			continue
+		}
+		name := ""
+		if spec.Name != nil {
+			name = spec.Name.Name
+		} else if pkg, err := c.importer.Import(path); err == nil {
+			name = pkg.Name()
+		} else {
+			log.Printf("callsites of package `%s`: %v", path, err)
+		}
+		if name == "" || name == "_" || name == "." {
+			continue
+		}
+		imports[name] = path
+	}
+	if len(imports) == 0 {
+		return false, nil
+	}
+
+	// References kept to the original functions so that their package imports
+	// are still used.
+	var refs []*dst.SelectorExpr
+	referenced := make(map[string]bool)
+	dstutil.Apply(file, func(cursor *dstutil.Cursor) bool {
+		if err != nil {
+			return false
+		}
+		sel, ok := cursor.Node().(*dst.SelectorExpr)
+		if !ok {
+			return true
+		}
+		x, ok := sel.X.(*dst.Ident)
+		// Local declarations shadowing the package name have an object.
+		if !ok || x.Obj != nil {
+			return true
+		}
+		path, ok := imports[x.Name]
+		if !ok {
+			return true
+		}
+		signatrue, ok := configs.LookupCallsite(path, sel.Sel.Name)
+		if !ok {
+			return true
+		}
+		var w *callsiteWrapper
+		w, err = c.wrapper(path, sel.Sel.Name, signatrue)
+		if err != nil || w == nil {
+			return false
+		}
+		log.Printf("Will hook callsite: %s\n", signatrue)
+		ident := dst.NewIdent(w.funcDecl.Name.Name)
+		ident.Decs.NodeDecs = sel.Decs.NodeDecs
+		cursor.Replace(ident)
+		if ref := x.Name + "." + sel.Sel.Name; !referenced[ref] {
+			referenced[ref] = true
+			refs = append(refs, &dst.SelectorExpr{X: dst.NewIdent(x.Name), Sel: dst.NewIdent(sel.Sel.Name)})
+		}
+		rewritten = true
+		return false
+	}, nil)
+	if err != nil {
+		return false, err
+	}
+
+	// var _ = pkg.Func
+	for _, ref := range refs {
+		file.Decls = append(file.Decls, &dst.GenDecl{
+			Tok: token.VAR,
+			Specs: []dst.Spec{
+				&dst.ValueSpec{
+					Names:  []*dst.Ident{dst.NewIdent("_")},
+					Values: []dst.Expr{ref},
+				},
+			},
+		})
+	}
+	return rewritten, nil
+}
+
+// undeclaredWrappers returns the wrappers that are not yet declared, and marks
+// them as declared.
+func (c *callsiteInstrumentation) undeclaredWrappers() (wrappers []*callsiteWrapper) {
+	for _, w := range c.created {
+		if !w.declared {
+			w.declared = true
+			wrappers = append(wrappers, w)
+		}
+	}
+	return wrappers
+}
+
+// wrapper returns the wrapper of function `name` of package `pkgPath`, created
+// on first use. It returns nil when the function cannot be wrapped.
+func (c *callsiteInstrumentation) wrapper(pkgPath, name, signatrue string) (*callsiteWrapper, error) {
+	if w, ok := c.wrappers[signatrue]; ok {
+		return w, nil
+	}
+	w, err := c.newWrapper(pkgPath, name, signatrue)
+	if err != nil {
+		return nil, err
+	}
+	c.wrappers[signatrue] = w
+	if w != nil {
+		c.created = append(c.created, w)
+	}
+	return w, nil
+}
+
+// newWrapper returns the wrapper function of the callsite function:
+//
+//	func _hook_wrapper_<id>(_param0 A, _param1 B) (_result0 R) {
+//		<instrumentation statement>
+//		_result0 = pkg.Func(_param0, _param1)
+//		return
+//	}
+func (c *callsiteInstrumentation) newWrapper(pkgPath, name, signatrue string) (*callsiteWrapper, error) {
+	pkg, err := c.importer.Import(pkgPath)
+	if err != nil {
+		return nil, fmt.Errorf("callsite `%s`: %w", signatrue, err)
+	}
+	fn, ok := pkg.Scope().Lookup(name).(*types.Func)
+	if !ok {
+		log.Printf("ignoring callsite `%s`: not a function", signatrue)
+		return nil, nil
+	}
+	sig := fn.Type().(*types.Signature)
+	if sig.TypeParams().Len() > 0 {
+		log.Printf("ignoring callsite `%s`: generic functions are not supported", signatrue)
+		return nil, nil
+	}
+	if t := unexportedType(sig); t != nil {
+		log.Printf("ignoring callsite `%s`: unexported type `%s`", signatrue, t)
+		return nil, nil
+	}
+
+	// The packages are imported with the names of the code snippet imports so
+	// that they don't conflict with the ones of the file.
+	var imports []configs.SnippetImport
+	qualifier := func(p *types.Package) string {
+		imp := configs.SnippetImport{Name: p.Name(), Path: p.Path()}
+		imports = appendSnippetImport(imports, imp)
+		return imp.Ident()
+	}
+	target := qualifier(pkg) + "." + name
+
+	var params, args, results, resultVars []string
+	for i := 0; i < sig.Params().Len(); i++ {
+		param := fmt.Sprintf("_param%d", i)
+		t := sig.Params().At(i).Type()
+		if sig.Variadic() && i == sig.Params().Len()-1 {
+			params = append(params, param+" ..."+typeString(t.(*types.Slice).Elem(), qualifier))
+			args = append(args, param+"...")
+			continue
+		}
+		params = append(params, param+" "+typeString(t, qualifier))
+		args = append(args, param)
+	}
+	for i := 0; i < sig.Results().Len(); i++ {
+		result := fmt.Sprintf("_result%d", i)
+		results = append(results, result+" "+typeString(sig.Results().At(i).Type(), qualifier))
+		resultVars = append(resultVars, result)
+	}
+
+	id := ast.CallsiteID(pkgPath, name, c.pkgPath)
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "package p\n\nfunc "+configs.CallsiteWrapperIdentFormat+"(%s) ", id, strings.Join(params, ", "))
+	if len(results) > 0 {
+		fmt.Fprintf(&src, "(%s) ", strings.Join(results, ", "))
+	}
+	src.WriteString("{\n")
+	call := fmt.Sprintf("%s(%s)", target, strings.Join(args, ", "))
+	if len(resultVars) > 0 {
+		fmt.Fprintf(&src, "\t%s = %s\n\treturn\n}\n", strings.Join(resultVars, ", "), call)
+	} else {
+		fmt.Fprintf(&src, "\t%s\n}\n", call)
+	}
+	file, err := decorator.Parse(src.String())
+	if err != nil {
+		return nil, fmt.Errorf("callsite `%s`: %w", signatrue, err)
+	}
+	funcDecl := file.Decls[0].(*dst.FuncDecl)
+
+	targetExpr := &dst.SelectorExpr{X: dst.NewIdent(qualifier(pkg)), Sel: dst.NewIdent(name)}
+	hook, err := ast.NewCallsiteHookpoint(signatrue, id, funcDecl, targetExpr, c.newHookDescriptorValueInitializer)
+	if err != nil {
+		return nil, err
+	}
+	funcDecl.Body.List = append([]dst.Stmt{hook.InstrumentationStmt}, funcDecl.Body.List...)
+	for _, imp := range imports {
+		hook.Imports = appendSnippetImport(hook.Imports, imp)
+	}
+	return &callsiteWrapper{funcDecl: funcDecl, hook: hook}, nil
+}
+
+func appendSnippetImport(imports []configs.SnippetImport, imp configs.SnippetImport) []configs.SnippetImport {
+	for _, each := range imports {
+		if each.Path == imp.Path {
+			return imports
+		}
+	}
+	return append(imports, imp)
+}
+
+// unexportedType returns the first unexported named type, or struct type with
+// unexported fields, of the signature, which cannot be referenced by the
+// wrapper, nil otherwise.
+func unexportedType(sig *types.Signature) types.Type {
+	var walk func(t types.Type) types.Type
+	seen := make(map[types.Type]bool)
+	walk = func(t types.Type) types.Type {
+		if seen[t] {
+			return nil
+		}
+		seen[t] = true
+		switch t := t.(type) {
+		case *types.Named:
+			if obj := t.Obj(); obj.Pkg() != nil && !obj.Exported() {
+				return t
+			}
+			for i := 0; i < t.TypeArgs().Len(); i++ {
+				if u := walk(t.TypeArgs().At(i)); u != nil {
+					return u
+				}
+			}
+		case *types.Pointer:
+			return walk(t.Elem())
+		case *types.Slice:
+			return walk(t.Elem())
+		case *types.Array:
+			return walk(t.Elem())
+		case *types.Chan:
+			return walk(t.Elem())
+		case *types.Map:
+			if u := walk(t.Key()); u != nil {
+				return u
+			}
+			return walk(t.Elem())
+		case *types.Signature:
+			for _, tuple := range []*types.Tuple{t.Params(), t.Results()} {
+				for i := 0; i < tuple.Len(); i++ {
+					if u := walk(tuple.At(i).Type()); u != nil {
+						return u
+					}
+				}
+			}
+		case *types.Struct:
+			for i := 0; i < t.NumFields(); i++ {
+				// Struct types having unexported fields are distinct in
+				// other packages.
+				if !t.Field(i).Exported() {
+					return t
+				}
+				if u := walk(t.Field(i).Type()); u != nil {
+					return u
+				}
+			}
+		case *types.Interface:
+			for i := 0; i < t.NumMethods(); i++ {
+				if u := walk(t.Method(i).Type()); u != nil {
+					return u
+				}
+			}
+		}
+		return nil
+	}
+	return walk(sig)
+}
+
+// typeString returns the source of the type like types.TypeString, but with
+// the predeclared `any` written `interface{}` since the instrumented package
+// can use a Go version prior to 1.18.
+func typeString(t types.Type, qualifier types.Qualifier) string {
+	if isUniverseAny(t) {
+		return "interface{}"
+	}
+	switch t := t.(type) {
+	case *types.Named:
+		if t.TypeArgs().Len() == 0 {
+			break
+		}
+		name := t.Obj().Name()
+		if pkg := t.Obj().Pkg(); pkg != nil {
+			name = qualifier(pkg) + "." + name
+		}
+		args := make([]string, t.TypeArgs().Len())
+		for i := range args {
+			args[i] = typeString(t.TypeArgs().At(i), qualifier)
+		}
+		return name + "[" + strings.Join(args, ", ") + "]"
+	case *types.Pointer:
+		return "*" + typeString(t.Elem(), qualifier)
+	case *types.Slice:
+		return "[]" + typeString(t.Elem(), qualifier)
+	case *types.Array:
+		return fmt.Sprintf("[%d]%s", t.Len(), typeString(t.Elem(), qualifier))
+	case *types.Map:
+		return "map[" + typeString(t.Key(), qualifier) + "]" + typeString(t.Elem(), qualifier)
+	case *types.Chan:
+		elem := typeString(t.Elem(), qualifier)
+		switch t.Dir() {
+		case types.SendOnly:
+			return "chan<- " + elem
+		case types.RecvOnly:
+			return "<-chan " + elem
+		}
+		if c, ok := t.Elem().(*types.Chan); ok && c.Dir() == types.RecvOnly {
+			elem = "(" + elem + ")"
+		}
+		return "chan " + elem
+	case *types.Signature:
+		return "func" + signatureString(t, qualifier)
+	case *types.Struct:
+		fields := make([]string, t.NumFields())
+		for i := range fields {
+			f := t.Field(i)
+			fields[i] = typeString(f.Type(), qualifier)
+			if !f.Embedded() {
+				fields[i] = f.Name() + " " + fields[i]
+			}
+			if tag := t.Tag(i); tag != "" {
+				fields[i] += " " + strconv.Quote(tag)
+			}
+		}
+		return "struct{" + strings.Join(fields, "; ") + "}"
+	case *types.Interface:
+		var elems []string
+		for i := 0; i < t.NumEmbeddeds(); i++ {
+			elems = append(elems, typeString(t.EmbeddedType(i), qualifier))
+		}
+		for i := 0; i < t.NumExplicitMethods(); i++ {
+			m := t.ExplicitMethod(i)
+			elems = append(elems, m.Name()+signatureString(m.Type().(*types.Signature), qualifier))
+		}
+		return "interface{" + strings.Join(elems, "; ") + "}"
+	}
+	return types.TypeString(t, qualifier)
+}
+
+// signatureString returns the parameters and results of the signature.
+func signatureString(sig *types.Signature, qualifier types.Qualifier) string {
+	tuple := func(tuple *types.Tuple, variadic bool) string {
+		list := make([]string, tuple.Len())
+		for i := range list {
+			t := tuple.At(i).Type()
+			if variadic && i == len(list)-1 {
+				list[i] = "..." + typeString(t.(*types.Slice).Elem(), qualifier)
+				continue
+			}
+			list[i] = typeString(t, qualifier)
+		}
+		return strings.Join(list, ", ")
+	}
+	s := "(" + tuple(sig.Params(), sig.Variadic()) + ")"
+	switch sig.Results().Len() {
+	case 0:
+		return s
+	case 1:
+		return s + " " + tuple(sig.Results(), false)
+	}
+	return s + " (" + tuple(sig.Results(), false) + ")"
+}
+
+// isUniverseAny returns true when the type is the predeclared `any`, an alias
+// type when go/types creates aliases.
+func isUniverseAny(t types.Type) bool {
+	obj := types.Universe.Lookup("any")
+	if alias, ok := t.(interface{ Obj() *types.TypeName }); ok {
+		return alias.Obj() == obj
+	}
+	return t == obj.Type()
+}
@@ -0,0 +1,29 @@
+package instrument
+
+import (
+	"fmt"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+)
+
+// ImportcfgFiles maps the import paths of the dependencies of the package
+// being compiled to their export data files, as listed by the importcfg file
+// of the compiler. It allows to type-check the functions instrumented at
+// their callsites and the package declaring interface method implementations.
+var ImportcfgFiles map[string]string
+
+// newImportcfgImporter returns the importer of the packages of the importcfg
+// file of the compiler, from their export data.
+func newImportcfgImporter() types.Importer {
+	lookup := func(path string) (io.ReadCloser, error) {
+		file, ok := ImportcfgFiles[path]
+		if !ok {
+			return nil, fmt.Errorf("package `%s` not found in the importcfg file", path)
+		}
+		return os.Open(file)
+	}
+	return importer.ForCompiler(token.NewFileSet(), "gc", lookup)
+}
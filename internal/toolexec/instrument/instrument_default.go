@@ -4,8 +4,6 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
-	"strings"
 
 	"github.com/ListenOcean/goHookTool/configs"
 	"github.com/ListenOcean/goHookTool/internal/toolexec/ast"
@@ -23,8 +21,7 @@ type defaultPackageInstrumentation struct {
 }
 
 func NewDefaultPackageInstrumentation(pkgPath string, fullInstrumentation bool, packageBuildDir string) *defaultPackageInstrumentation {
-	projectBuildDir := filepath.Join(packageBuildDir, "..")
-	hookListFilepath := getHookListFilepath(projectBuildDir)
+	hookListFilepath := getHookListFilepath(ProjectBuildDir(packageBuildDir))
 
 	return &defaultPackageInstrumentation{
 		packageInstrumentationHelper: makePackageInstrumentationHelper(pkgPath),
@@ -40,18 +37,21 @@ func (h *defaultPackageInstrumentation) IsIgnored() bool {
 }
 
 func (h *defaultPackageInstrumentation) isPackageIgnored() bool {
-	for _, prefix := range configs.IgnoredPkgPrefixes {
-		if strings.HasPrefix(h.pkgPath, prefix) {
-			return true
-		}
+	if _, ignored := configs.IgnoredPackage(h.pkgPath); ignored {
+		return true
 	}
 
 	if h.fullInstrumentation {
 		return false
 	}
 
-	// 当前包名在HookPointMap中，说明需要被插桩
-	if _, ok := configs.HookPointMap[h.pkgPath]; ok {
+	// 当前包名在HookPointMap中或匹配通配符包，说明需要被插桩
+	if configs.HasHookpoints(h.pkgPath) {
+		return false
+	}
+
+	// 包中可能存在对调用点插桩函数的调用，或者实现了接口方法 Hook 点的类型
+	if configs.HasCallsites() || configs.HasInterfaces() {
 		return false
 	}
 
@@ -64,7 +64,8 @@ func UnvendorPackagePath(pkg string) (unvendored string) {
 
 func (h *defaultPackageInstrumentation) Instrument() (instrumented []*dst.File, err error) {
 	h.instrumentedFiles = make(map[*dst.File][]*ast.Hookpoint)
-	v := newDefaultPackageInstrumentationVisitor(h.pkgPath, h.instrumentedFiles)
+	v := newDefaultPackageInstrumentationVisitor(h.pkgPath, h.instrumentedFiles, &h.stats)
+	v.interfaceMethods = h.interfaceImplementations()
 	return h.packageInstrumentationHelper.instrument(v)
 }
 
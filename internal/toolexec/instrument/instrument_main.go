@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/ListenOcean/goHookTool/configs"
@@ -18,6 +19,10 @@ type mainPackageInstrumentation struct {
 	*defaultPackageInstrumentation
 }
 
+// MainImportPath is the import path of the main package being compiled, whose
+// compiler package path is `main`.
+var MainImportPath string
+
 func NewMainPackageInstrumentation(pkgPath string, fullInstrumentation bool, packageBuildDir string) *mainPackageInstrumentation {
 	return &mainPackageInstrumentation{
 		defaultPackageInstrumentation: NewDefaultPackageInstrumentation(pkgPath, fullInstrumentation, packageBuildDir),
@@ -67,6 +72,7 @@ func removeBracketsAndStar(s string) string {
 func normalizedSignatrue(pkgpath, sign string) string {
 	normalizedPkgPath := regexp.MustCompile(`[/.\-@]`).ReplaceAllString(pkgpath, "_")
 	sign = strings.TrimPrefix(sign, pkgpath+".")
+	sign = strings.ReplaceAll(sign, configs.TypeParamsSignatrue, "")
 	signSlice := strings.Split(sign, ".")
 	nameSlice := []string{}
 	for _, eachStr := range signSlice {
@@ -76,6 +82,44 @@ func normalizedSignatrue(pkgpath, sign string) string {
 
 }
 
+// patternInstrumented returns true when one of the instrumented signatures
+// matches the hookpoint pattern.
+func patternInstrumented(source string, signatrues []string) bool {
+	pattern, err := configs.CompileHookpointPattern(source)
+	if err != nil {
+		return false
+	}
+	for _, signatrue := range signatrues {
+		if pattern.Match(signatrue) {
+			return true
+		}
+	}
+	return false
+}
+
+// wildcardInstrumented returns true when the hookpoint of the package wildcard
+// got instrumented in one of its matching packages.
+func wildcardInstrumented(w *configs.PackageWildcard, hookpoint string, instrumented map[string][]string) bool {
+	for pkgPath, signatrues := range instrumented {
+		if !w.Match(pkgPath) {
+			continue
+		}
+		expanded := w.Expand(pkgPath, hookpoint)
+		if configs.IsHookpointPattern(expanded) {
+			if patternInstrumented(expanded, signatrues) {
+				return true
+			}
+			continue
+		}
+		for _, signatrue := range signatrues {
+			if signatrue == expanded {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (m *mainPackageInstrumentation) writeHookTable() (string, error) {
 	// Create the hook table and compile it.
 	// Get the full list of hooks
@@ -87,23 +131,106 @@ func (m *mainPackageInstrumentation) writeHookTable() (string, error) {
 	hookPointSet := make(map[string]struct{})
 	for _, hookpoint := range hooks {
 		hp := strings.TrimPrefix(hookpoint, configs.HookDescriptorIdentPrefix)
-		hookPointSet[hp] = struct{}{}
+		// The init functions of a package share their hookpoint
+		hookPointSet[configs.InitTargetID(hp)] = struct{}{}
 	}
 	log.Printf("Not Hooked:\n")
 
+	reportListFilepath := getReportListFilepath(ProjectBuildDir(m.packageBuildDir))
+	packageReports, err := readReportListFile(reportListFilepath)
+	if err != nil {
+		return "", err
+	}
+	// Signatures of the instrumented functions, by package
+	instrumented := make(map[string][]string)
+	// Signatures of the instrumented methods, by interface method
+	implementations := make(map[string][]string)
+	for _, r := range packageReports {
+		for _, each := range r.Instrumented {
+			instrumented[r.PkgPath] = append(instrumented[r.PkgPath], each.Signature)
+			for _, iface := range each.Interfaces {
+				implementations[iface] = append(implementations[iface], each.Signature)
+			}
+		}
+	}
+
 	countConfigHookPoint := 0
+	var notHooked []PlannedHookpoint
 	for pkgname, mapdata := range configs.HookPointMap {
 		for signatrue := range mapdata {
 			countConfigHookPoint += 1
+			if configs.IsHookpointPattern(signatrue) {
+				if !patternInstrumented(signatrue, instrumented[pkgname]) {
+					log.Printf("%s\n", signatrue)
+					notHooked = append(notHooked, PlannedHookpoint{
+						Signature: signatrue,
+						Status:    StatusNotFound,
+						Reason:    "package not part of the build, ignored, or no function matches the pattern",
+					})
+				}
+				continue
+			}
 			hookpoint := normalizedSignatrue(pkgname, signatrue)
 			if _, ok := hookPointSet[hookpoint]; !ok {
 				log.Printf("%s\n", hookpoint)
+				notHooked = append(notHooked, PlannedHookpoint{
+					Signature: signatrue,
+					Status:    StatusNotFound,
+					Reason:    "package not part of the build, ignored, or function not found",
+				})
+			}
+		}
+	}
+	for _, w := range configs.PackageWildcards {
+		reason := "no matching package part of the build, or no function matches the hookpoint"
+		if MainImportPath != "" && w.Match(MainImportPath) {
+			reason += "; " + mainPackageReason
+		}
+		for _, hookpoint := range w.Hookpoints {
+			countConfigHookPoint += 1
+			if !wildcardInstrumented(w, hookpoint, instrumented) {
+				log.Printf("%s: %s\n", w.Pattern, hookpoint)
+				notHooked = append(notHooked, PlannedHookpoint{
+					Signature: w.Pattern + ": " + hookpoint,
+					Status:    StatusNotFound,
+					Reason:    reason,
+				})
+			}
+		}
+	}
+	for _, methods := range configs.InterfaceMethods {
+		for _, m := range methods {
+			countConfigHookPoint += 1
+			if len(implementations[m.Signatrue]) == 0 {
+				log.Printf("%s\n", m.Signatrue)
+				notHooked = append(notHooked, PlannedHookpoint{
+					Signature: m.Signatrue,
+					Status:    StatusNotFound,
+					Reason:    "no implementation in the instrumented packages importing the interface package",
+				})
 			}
 		}
 	}
 	log.Printf("Loaded %d HookPoints in configs.", countConfigHookPoint)
 	log.Printf("Hooked %d HookPoints in hooktable.", len(hooks))
 
+	sort.Slice(notHooked, func(i, j int) bool {
+		return notHooked[i].Signature < notHooked[j].Signature
+	})
+
+	// The report is also written when strict mode fails the build, to tell
+	// why the hookpoints are missing.
+	if ReportFilepath != "" {
+		if err := writeReport(ReportFilepath, packageReports, hooks, notHooked); err != nil {
+			return "", err
+		}
+		log.Printf("instrumentation report written into `%s`", ReportFilepath)
+	}
+
+	if Strict && len(notHooked) > 0 {
+		return "", &UnmatchedHookpointsError{Hookpoints: notHooked}
+	}
+
 	if len(hooks) == 0 {
 		log.Printf("skipping hook table generation: the list of hooks is empty")
 		return "", nil
@@ -116,7 +243,7 @@ func (m *mainPackageInstrumentation) writeHookTable() (string, error) {
 	}
 	defer hookTableFile.Close()
 	log.Printf("creating the hook table for %d hooks from `%s` into `%s`", len(hooks), m.hookListFilepath, hookTableFile.Name())
-	if err := writeHookTable(hookTableFile, hooks); err != nil {
+	if err := writeHookTable(hookTableFile, hooks, implementations); err != nil {
 		return "", err
 	}
 
@@ -139,7 +266,9 @@ func getHookListFilepath(dir string) string {
 }
 
 // Read the given hook list file by reopening it and reading its full content,
-// returned as a slice of hook IDs.
+// returned as a slice of hook IDs. A package compiled both by the build and by
+// the nested build of the packages imported by the code snippets lists its
+// hooks twice, which are only returned once.
 func readHookListFile(hookListFilepath string) (hooks []string, err error) {
 	f, err := os.OpenFile(hookListFilepath, os.O_RDONLY, 0666)
 	if os.IsNotExist(err) {
@@ -151,8 +280,13 @@ func readHookListFile(hookListFilepath string) (hooks []string, err error) {
 	defer f.Close()
 	// Read each hook id line by line
 	scanner := bufio.NewScanner(f)
+	seen := make(map[string]bool)
 	for scanner.Scan() {
 		id := scanner.Text()
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
 		hooks = append(hooks, id)
 	}
 	return
@@ -12,6 +12,10 @@ import (
 	"github.com/dave/dst"
 )
 
+// Go version of the compiler, selecting the runtime extension file. The
+// newest extension is used when empty.
+var GoVersion string
+
 type runtimePackageInstrumentation struct {
 	packageInstrumentationHelper
 	instrumentedFiles   map[*dst.File][]*ast.Hookpoint
@@ -21,8 +25,7 @@ type runtimePackageInstrumentation struct {
 }
 
 func NewRuntimePackageInstrumentation(pkgPath string, fullInstrumentation bool, packageBuildDir string) *runtimePackageInstrumentation {
-	projectBuildDir := filepath.Join(packageBuildDir, "..")
-	hookListFilepath := getHookListFilepath(projectBuildDir)
+	hookListFilepath := getHookListFilepath(ProjectBuildDir(packageBuildDir))
 	return &runtimePackageInstrumentation{
 		packageInstrumentationHelper: makePackageInstrumentationHelper(pkgPath),
 		fullInstrumentation:          fullInstrumentation,
@@ -38,7 +41,7 @@ func (runtimePackageInstrumentation) IsIgnored() bool {
 
 func (h *runtimePackageInstrumentation) Instrument() (instrumented []*dst.File, err error) {
 	h.instrumentedFiles = make(map[*dst.File][]*ast.Hookpoint)
-	v := newRuntimeInstrumentationVisitor(h.pkgPath, h.instrumentedFiles)
+	v := newRuntimeInstrumentationVisitor(h.pkgPath, h.instrumentedFiles, &h.stats)
 	return h.packageInstrumentationHelper.instrument(v)
 }
 
@@ -68,7 +71,7 @@ func (h *runtimePackageInstrumentation) WriteExtraFiles() ([]string, error) {
 	log.Printf("added %d hooks to the hook list\n", count)
 
 	rtExtensions := filepath.Join(h.packageBuildDir, "autobuild.go")
-	if err := os.WriteFile(rtExtensions, []byte(configs.RuntimeExtraFileContent), 0644); err != nil {
+	if err := os.WriteFile(rtExtensions, []byte(configs.RuntimeExtraFileContent(GoVersion)), 0644); err != nil {
 		return nil, err
 	}
 	return []string{rtExtensions}, nil
@@ -1,14 +1,17 @@
 package instrument
 
 import (
+	"bytes"
 	"fmt"
 	"go/parser"
 	"go/token"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/ListenOcean/goHookTool/configs"
 	"github.com/ListenOcean/goHookTool/internal/toolexec/ast"
 
 	"github.com/dave/dst"
@@ -23,11 +26,39 @@ type Instrumenter interface {
 	WriteExtraFiles() ([]string, error)
 }
 
+// NewPackageInstrumentation 根据包名返回对应的构建器
+func NewPackageInstrumentation(pkgPath string, fullInstrumentation bool, packageBuildDir string) Instrumenter {
+	switch pkgPath {
+	case "runtime":
+		return NewRuntimePackageInstrumentation(pkgPath, fullInstrumentation, packageBuildDir)
+	case "main":
+		return NewMainPackageInstrumentation(pkgPath, fullInstrumentation, packageBuildDir)
+	default:
+		return NewDefaultPackageInstrumentation(pkgPath, fullInstrumentation, packageBuildDir)
+	}
+}
+
+// ProjectBuildDir returns the build work directory of the package build
+// directory, where the hook list and the package reports are written. The
+// packages imported by the code snippets are built by a nested go command,
+// given the work directory of the outer build by CUSTOMWORKDIR.
+func ProjectBuildDir(packageBuildDir string) string {
+	if dir := os.Getenv(configs.TagCustomWorkDir); dir != "" {
+		return dir
+	}
+	return filepath.Join(packageBuildDir, "..")
+}
+
 type packageInstrumentationHelper struct {
 	parsedFiles       map[string]*dst.File
 	parsedFileSources map[*dst.File]string
 	fset              *token.FileSet
 	pkgPath           string
+	// Decorators of the parsed files, mapping their nodes to the original
+	// source positions.
+	decorators map[*dst.File]*decorator.Decorator
+	// Instrumentation statistics filled by the visitor.
+	stats instrumentationStats
 }
 
 func makePackageInstrumentationHelper(pkgPath string) packageInstrumentationHelper {
@@ -57,7 +88,8 @@ func (h *packageInstrumentationHelper) AddFile(src string) error {
 		// The token fileset is required to later create the package node.
 		h.fset = token.NewFileSet()
 	}
-	file, err := decorator.ParseFile(h.fset, src, nil, parser.ParseComments)
+	dec := decorator.NewDecorator(h.fset)
+	file, err := dec.ParseFile(src, nil, parser.ParseComments)
 	if err != nil {
 		return err
 	}
@@ -70,12 +102,18 @@ func (h *packageInstrumentationHelper) AddFile(src string) error {
 	if h.parsedFiles == nil {
 		h.parsedFiles = make(map[string]*dst.File)
 		h.parsedFileSources = make(map[*dst.File]string)
+		h.decorators = make(map[*dst.File]*decorator.Decorator)
 	}
 	h.parsedFiles[src] = file
 	h.parsedFileSources[file] = src
+	h.decorators[file] = dec
 	return nil
 }
 
+func (h *packageInstrumentationHelper) helper() *packageInstrumentationHelper {
+	return h
+}
+
 func isFileNameIgnored(file string) bool {
 	filename := filepath.Base(file)
 	// Don't instrument cgo files
@@ -100,7 +138,7 @@ func (h *packageInstrumentationHelper) instrument(v instrumentationVisitorFace)
 		return nil, err
 	}
 
-	return v.instrument(root), nil
+	return v.instrument(root)
 }
 
 func (h *packageInstrumentationHelper) WriteInstrumentedFiles(buildDirPath string, instrumentedFiles []*dst.File) (srcdst map[string]string, err error) {
@@ -114,13 +152,7 @@ func (h *packageInstrumentationHelper) WriteInstrumentedFiles(buildDirPath strin
 			return nil, err
 		}
 		defer output.Close()
-		// Add a go line directive in order to map it to its original source file.
-		// Note that otherwise it uses the build directory but it is trimmed by the
-		// compiler - so you end up with filenames without any leading path (eg.
-		// myfile.go) leading to broken debuggers or stack traces.
-		// 添加go line编译指令`//line <filename>:1`，使得编译器构建时能够将其映射到原始文件，而不使用临时目录中的文件
-		output.WriteString(fmt.Sprintf("//line %s:1\n", src))
-		if err := ast.WriteFile(node, output); err != nil {
+		if err := h.writeInstrumentedFile(output, node, dest); err != nil {
 			return nil, err
 		}
 		srcdst[src] = dest
@@ -128,39 +160,42 @@ func (h *packageInstrumentationHelper) WriteInstrumentedFiles(buildDirPath strin
 	return srcdst, nil
 }
 
-//func getToken(length int) string {
-//	randomBytes := make([]byte, 32)
-//	_, err := rand.Read(randomBytes)
-//	if err != nil {
-//		panic(err)
-//	}
-//	return base32.StdEncoding.EncodeToString(randomBytes)[:length]
-//}
-
-// Debug用途，将修改后的文件随机命名，配合脚本转移到debug目录，使用dlv时可以索引到源码
-
-//func (h *packageInstrumentationHelper) WriteInstrumentedFiles(buildDirPath string, instrumentedFiles []*dst.File) (srcdst map[string]string, err error) {
-//	srcdst = make(map[string]string, len(instrumentedFiles))
-//	for _, node := range instrumentedFiles {
-//		src := h.parsedFileSources[node]
-//		//filename := filepath.Base(src)
-//		//dest := filepath.Join(buildDirPath, filename)
-//		dest := filepath.Join(buildDirPath, getToken(20)+".go")
-//		output, err := os.Create(dest)
-//		if err != nil {
-//			return nil, err
-//		}
-//		defer output.Close()
-//		// Add a go line directive in order to map it to its original source file.
-//		// Note that otherwise it uses the build directory but it is trimmed by the
-//		// compiler - so you end up with filenames without any leading path (eg.
-//		// myfile.go) leading to broken debuggers or stack traces.
-//		// 添加go line编译指令`//line <filename>:1`，使得编译器构建时能够将其映射到原始文件，而不使用临时目录中的文件
-//		//output.WriteString(fmt.Sprintf("//line %s:1\n", src))
-//		if err := ast.WriteFile(node, output); err != nil {
-//			return nil, err
-//		}
-//		srcdst[src] = dest
-//	}
-//	return srcdst, nil
-//}
+// writeInstrumentedFile writes into `w` the Go sources of the instrumented
+// file node, as given to the compiler, written into `dest`.
+func (h *packageInstrumentationHelper) writeInstrumentedFile(w io.Writer, node *dst.File, dest string) error {
+	if LineDirectives != LineDirectivesOriginal {
+		return ast.WriteFile(node, w)
+	}
+	var buf bytes.Buffer
+	src := lineDirectiveFilename(h.parsedFileSources[node])
+	// Add a go line directive in order to map it to its original source file.
+	// Note that otherwise it uses the build directory but it is trimmed by the
+	// compiler - so you end up with filenames without any leading path (eg.
+	// myfile.go) leading to broken debuggers or stack traces.
+	// 添加go line编译指令`//line <filename>:1`，使得编译器构建时能够将其映射到原始文件，而不使用临时目录中的文件
+	fmt.Fprintf(&buf, "//line %s:1\n", src)
+	// The injected code shifts the original lines: map them back too.
+	h.addLineDirectives(node, src, dest)
+	if err := ast.WriteFile(node, &buf); err != nil {
+		return err
+	}
+	_, err := w.Write(resolveLineDirectives(buf.Bytes(), dest))
+	return err
+}
+
+// SnippetImportPaths returns the import paths of the packages imported by the
+// code snippets of the instrumented functions.
+func SnippetImportPaths(i Instrumenter) []string {
+	h := i.(helperGetter).helper()
+	var paths []string
+	seen := make(map[string]bool)
+	for _, hook := range h.stats.instrumented {
+		for _, imp := range hook.Imports {
+			if !seen[imp.Path] {
+				seen[imp.Path] = true
+				paths = append(paths, imp.Path)
+			}
+		}
+	}
+	return paths
+}
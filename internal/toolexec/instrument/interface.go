@@ -0,0 +1,141 @@
+package instrument
+
+import (
+	goast "go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"log"
+	"sort"
+
+	"github.com/ListenOcean/goHookTool/configs"
+
+	"github.com/dave/dst"
+)
+
+// interfaceImplementations type-checks the package against the importcfg file
+// of the compiler and returns the configured interface methods implemented by
+// the methods it declares, by method signature. Only the interfaces of the
+// package itself and of the packages it imports can be implemented.
+func (h *packageInstrumentationHelper) interfaceImplementations() map[string][]string {
+	if !configs.HasInterfaces() || ImportcfgFiles == nil || !h.declaresMethods(configs.InterfaceMethodNames()) {
+		return nil
+	}
+
+	fset := token.NewFileSet()
+	var files []*goast.File
+	for _, src := range h.parsedFileSources {
+		file, err := parser.ParseFile(fset, src, nil, 0)
+		if err != nil {
+			log.Printf("interface methods: %v", err)
+			return nil
+		}
+		files = append(files, file)
+	}
+	imp := newImportcfgImporter()
+	conf := types.Config{
+		Importer:    imp,
+		FakeImportC: true,
+		// Best effort: files can be missing, eg. when ignored by a directive.
+		Error: func(error) {},
+	}
+	pkg, _ := conf.Check(h.pkgPath, fset, files, nil)
+	if pkg == nil {
+		return nil
+	}
+
+	implementations := make(map[string][]string)
+	for pkgPath, methods := range configs.InterfaceMethods {
+		ifacePkg := pkg
+		if pkgPath != h.pkgPath {
+			if _, ok := ImportcfgFiles[pkgPath]; !ok {
+				continue
+			}
+			var err error
+			if ifacePkg, err = imp.Import(pkgPath); err != nil {
+				log.Printf("interface methods of package `%s`: %v", pkgPath, err)
+				continue
+			}
+		}
+		for _, m := range methods {
+			iface := lookupInterface(ifacePkg, m)
+			if iface == nil {
+				continue
+			}
+			for _, signatrue := range h.implementingMethods(pkg, iface, m.Method) {
+				log.Printf("`%s` implements `%s`", signatrue, m.Signatrue)
+				implementations[signatrue] = append(implementations[signatrue], m.Signatrue)
+			}
+		}
+	}
+	return implementations
+}
+
+// declaresMethods returns true when the package declares a method having one
+// of the given names, avoiding to type-check packages which cannot implement
+// the interface methods.
+func (h *packageInstrumentationHelper) declaresMethods(names map[string]bool) bool {
+	for _, file := range h.parsedFiles {
+		for _, decl := range file.Decls {
+			if funcDecl, ok := decl.(*dst.FuncDecl); ok && funcDecl.Recv != nil && names[funcDecl.Name.Name] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lookupInterface returns the interface of the interface method, nil when it
+// doesn't exist.
+func lookupInterface(pkg *types.Package, m configs.InterfaceMethod) *types.Interface {
+	obj, ok := pkg.Scope().Lookup(m.Interface).(*types.TypeName)
+	if !ok {
+		log.Printf("interface method `%s`: no such type", m.Signatrue)
+		return nil
+	}
+	iface, ok := obj.Type().Underlying().(*types.Interface)
+	if !ok {
+		log.Printf("interface method `%s`: not an interface type", m.Signatrue)
+		return nil
+	}
+	for i := 0; i < iface.NumMethods(); i++ {
+		if iface.Method(i).Name() == m.Method {
+			return iface
+		}
+	}
+	log.Printf("interface method `%s`: no such method", m.Signatrue)
+	return nil
+}
+
+// implementingMethods returns the signatures of the methods named `method` of
+// the package types implementing the interface.
+func (h *packageInstrumentationHelper) implementingMethods(pkg *types.Package, iface *types.Interface, method string) (signatrues []string) {
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		obj, ok := scope.Lookup(name).(*types.TypeName)
+		if !ok || obj.IsAlias() {
+			continue
+		}
+		named, ok := obj.Type().(*types.Named)
+		if !ok || named.TypeParams().Len() > 0 || types.IsInterface(named) {
+			continue
+		}
+		if !types.Implements(named, iface) && !types.Implements(types.NewPointer(named), iface) {
+			continue
+		}
+		for i := 0; i < named.NumMethods(); i++ {
+			m := named.Method(i)
+			if m.Name() != method {
+				continue
+			}
+			recv := m.Type().(*types.Signature).Recv().Type()
+			if _, pointer := recv.(*types.Pointer); pointer {
+				signatrues = append(signatrues, h.pkgPath+".(*"+name+")."+method)
+			} else {
+				signatrues = append(signatrues, h.pkgPath+"."+name+"."+method)
+			}
+		}
+	}
+	sort.Strings(signatrues)
+	return signatrues
+}
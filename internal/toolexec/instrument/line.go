@@ -0,0 +1,118 @@
+package instrument
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/dave/dst"
+)
+
+// Line directive modes of the instrumented files.
+const (
+	// Map the positions of the original code back to the original source
+	// file, so that debuggers and stack traces show the original sources.
+	LineDirectivesOriginal = "original"
+	// Keep the positions of the instrumented file so that debuggers and stack
+	// traces step through the injected prolog/epilog code.
+	LineDirectivesInstrumented = "instrumented"
+)
+
+// LineDirectives is the line directive mode used when writing instrumented
+// files.
+var LineDirectives = LineDirectivesOriginal
+
+// Line of the line directives mapping the injected declarations to the
+// instrumented file, resolved once the file is printed.
+const unresolvedLine = 0
+
+// addLineDirectives adds a line directive before every original top-level
+// declaration and before every original statement following an injected one,
+// so that the injected code doesn't shift the positions of the original code.
+// Injected declarations following original ones are mapped back to the
+// instrumented file `dest`.
+func (h *packageInstrumentationHelper) addLineDirectives(node *dst.File, src, dest string) {
+	dec := h.decorators[node]
+	if dec == nil {
+		return
+	}
+	line := func(n dst.Node) (int, bool) {
+		astNode, ok := dec.Ast.Nodes[n]
+		if !ok {
+			// Injected node
+			return 0, false
+		}
+		return dec.Fset.Position(astNode.Pos()).Line, true
+	}
+
+	// The file starts with a line directive to the original file.
+	original := true
+	for _, decl := range node.Decls {
+		l, ok := line(decl)
+		if !ok {
+			if original {
+				// Otherwise mapped to the lines following the previous
+				// original declaration, eg. the hook descriptors appended at
+				// the end of the file.
+				addLineDirective(decl.Decorations(), fmt.Sprintf("//line %s:%d", dest, unresolvedLine))
+			}
+			original = false
+			continue
+		}
+		original = true
+		// The `//line` form must start at the beginning of a line, which is
+		// always the case of top-level declarations.
+		addLineDirective(decl.Decorations(), fmt.Sprintf("//line %s:%d", src, l))
+
+		funcDecl, ok := decl.(*dst.FuncDecl)
+		if !ok || funcDecl.Body == nil {
+			continue
+		}
+		injected := false
+		for _, stmt := range funcDecl.Body.List {
+			l, ok := line(stmt)
+			if !ok {
+				injected = true
+				continue
+			}
+			if injected {
+				// Statements are indented so the `/*line */` form is required.
+				addLineDirective(stmt.Decorations(), fmt.Sprintf("/*line %s:%d*/", src, l))
+				injected = false
+			}
+		}
+	}
+}
+
+func addLineDirective(decs *dst.NodeDecs, directive string) {
+	if all := decs.Start.All(); len(all) > 0 {
+		last := all[len(all)-1]
+		if strings.HasPrefix(last, "//line ") || strings.HasPrefix(last, "/*line ") {
+			// Already added
+			return
+		}
+	}
+	decs.Start.Append(directive)
+}
+
+// resolveLineDirectives sets the line of the line directives to the
+// instrumented file `dest` of the printed file: the one following them.
+func resolveLineDirectives(data []byte, dest string) []byte {
+	unresolved := fmt.Sprintf("//line %s:%d", dest, unresolvedLine)
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	for i, line := range lines {
+		if string(bytes.TrimSpace(line)) == unresolved {
+			lines[i] = []byte(fmt.Sprintf("//line %s:%d\n", dest, i+2))
+		}
+	}
+	return bytes.Join(lines, nil)
+}
+
+// Absolute path of the source file to use in line directives.
+func lineDirectiveFilename(src string) string {
+	if abs, err := filepath.Abs(src); err == nil {
+		return abs
+	}
+	return src
+}
@@ -0,0 +1,284 @@
+package instrument
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ListenOcean/goHookTool/configs"
+	"github.com/ListenOcean/goHookTool/internal/toolexec/ast"
+)
+
+// Status of a configured hookpoint after a dry-run instrumentation.
+const (
+	StatusInstrumented = "instrumented"
+	StatusIgnored      = "ignored"
+	StatusNotFound     = "not found"
+)
+
+// PackagePlan is the result of the dry-run instrumentation of a package.
+type PackagePlan struct {
+	PkgPath    string
+	Hookpoints []PlannedHookpoint
+	// Instrumented Go sources, without the line directives given to the
+	// compiler, by original file.
+	Sources map[string]string
+}
+
+// PlannedHookpoint tells what the instrumentation does with a hookpoint of the
+// configuration.
+type PlannedHookpoint struct {
+	Signature string `json:"signature"`
+	Status    string `json:"status"`
+	// Reason why the hookpoint is ignored or not found.
+	Reason string `json:"reason,omitempty"`
+	// Closest existing function signatures of a hookpoint not found.
+	Closest []string `json:"closest,omitempty"`
+	// Signatures of the instrumented functions a pattern expanded to.
+	Expanded []string `json:"expanded,omitempty"`
+}
+
+type helperGetter interface {
+	helper() *packageInstrumentationHelper
+}
+
+// DryRun parses and instruments the given package files in memory, without
+// writing anything, and returns what happened to every hookpoint configured
+// for this package.
+func DryRun(pkgPath string, files []string, fullInstrumentation bool) (*PackagePlan, error) {
+	pkgPath = UnvendorPackagePath(pkgPath)
+	plan := &PackagePlan{PkgPath: pkgPath}
+	signatrues := configuredSignatrues(pkgPath)
+	if len(signatrues) == 0 {
+		return plan, nil
+	}
+
+	i := NewPackageInstrumentation(pkgPath, fullInstrumentation, "")
+	if i.IsIgnored() {
+		// The package has hookpoints so it can only be ignored by the ignore
+		// lists.
+		pattern, _ := configs.IgnoredPackage(pkgPath)
+		for _, signatrue := range signatrues {
+			plan.Hookpoints = append(plan.Hookpoints, PlannedHookpoint{
+				Signature: signatrue,
+				Status:    StatusIgnored,
+				Reason:    fmt.Sprintf("package ignored by `%s`", pattern),
+			})
+		}
+		return plan, nil
+	}
+
+	for _, src := range files {
+		if err := i.AddFile(src); err != nil {
+			return nil, err
+		}
+	}
+	instrumentedFiles, err := i.Instrument()
+	if err != nil {
+		return nil, err
+	}
+
+	h := i.(helperGetter).helper()
+	plan.Sources = make(map[string]string, len(instrumentedFiles))
+	for _, node := range instrumentedFiles {
+		var source strings.Builder
+		// Without line directives, which would hide the injected code.
+		if err := ast.WriteFile(node, &source); err != nil {
+			return nil, err
+		}
+		plan.Sources[h.parsedFileSources[node]] = source.String()
+	}
+
+	plan.Hookpoints = h.plannedHookpoints(signatrues)
+	return plan, nil
+}
+
+// plannedHookpoints classifies the given configured signatures according to
+// the instrumentation statistics of the package.
+func (h *packageInstrumentationHelper) plannedHookpoints(signatrues []string) []PlannedHookpoint {
+	instrumented := make(map[string]struct{})
+	for _, hook := range h.stats.instrumented {
+		instrumented[hook.Signature] = struct{}{}
+	}
+	ignored := make(map[string]string, len(h.stats.ignored))
+	for _, each := range h.stats.ignored {
+		ignored[each.signatrue] = each.reason
+	}
+
+	planned := make([]PlannedHookpoint, 0, len(signatrues))
+	for _, signatrue := range signatrues {
+		hookpoint := PlannedHookpoint{Signature: signatrue}
+		if configs.IsHookpointPattern(signatrue) {
+			planned = append(planned, h.plannedPattern(signatrue))
+			continue
+		}
+		if _, ok := instrumented[signatrue]; ok {
+			hookpoint.Status = StatusInstrumented
+		} else if reason, ok := ignored[signatrue]; ok {
+			hookpoint.Status = StatusIgnored
+			hookpoint.Reason = reason
+		} else {
+			hookpoint.Status = StatusNotFound
+			hookpoint.Reason = "no such function declaration"
+			if generic := genericSignatrue(signatrue, h.stats.funcs); generic != "" {
+				hookpoint.Reason = fmt.Sprintf("generic function: the hookpoint is `%s`", generic)
+			}
+			hookpoint.Closest = closestSignatrues(signatrue, h.stats.funcs, maxClosestSignatrues)
+		}
+		planned = append(planned, hookpoint)
+	}
+	return planned
+}
+
+// genericSignatrue returns the signature of the generic function or method
+// the signature without type parameters refers to, if any.
+func genericSignatrue(signatrue string, funcs []string) string {
+	for _, each := range funcs {
+		if each != signatrue && strings.ReplaceAll(each, configs.TypeParamsSignatrue, "") == signatrue {
+			return each
+		}
+	}
+	return ""
+}
+
+// plannedPattern returns what the instrumentation does with the functions
+// matching a configured pattern.
+func (h *packageInstrumentationHelper) plannedPattern(source string) PlannedHookpoint {
+	hookpoint := PlannedHookpoint{Signature: source}
+	if expanded := h.stats.expanded[source]; len(expanded) > 0 {
+		hookpoint.Status = StatusInstrumented
+		hookpoint.Expanded = append([]string{}, expanded...)
+		sort.Strings(hookpoint.Expanded)
+		return hookpoint
+	}
+
+	pattern, err := configs.CompileHookpointPattern(source)
+	if err != nil {
+		// Already checked when reading the config.
+		hookpoint.Status = StatusNotFound
+		hookpoint.Reason = err.Error()
+		return hookpoint
+	}
+	var ignored []string
+	for _, each := range h.stats.ignored {
+		if pattern.Match(each.signatrue) {
+			ignored = append(ignored, fmt.Sprintf("%s (%s)", each.signatrue, each.reason))
+		}
+	}
+	if len(ignored) > 0 {
+		hookpoint.Status = StatusIgnored
+		hookpoint.Reason = "every matching function is ignored: " + strings.Join(ignored, ", ")
+	} else {
+		hookpoint.Status = StatusNotFound
+		hookpoint.Reason = "no function matches the pattern"
+	}
+	return hookpoint
+}
+
+// Maximum number of closest signatures suggested for a hookpoint not found.
+const maxClosestSignatrues = 3
+
+// closestSignatrues returns the signatures the closest to the given one, in
+// terms of edit distance.
+func closestSignatrues(signatrue string, candidates []string, n int) []string {
+	type candidate struct {
+		signatrue string
+		distance  int
+	}
+	// Ignore candidates that are too different to be a typo.
+	maxDistance := len(signatrue) / 3
+	var closest []candidate
+	for _, each := range candidates {
+		if d := editDistance(signatrue, each); d <= maxDistance {
+			closest = append(closest, candidate{signatrue: each, distance: d})
+		}
+	}
+	sort.SliceStable(closest, func(i, j int) bool {
+		return closest[i].distance < closest[j].distance
+	})
+	var res []string
+	for i := 0; i < len(closest) && i < n; i++ {
+		res = append(res, closest[i].signatrue)
+	}
+	return res
+}
+
+// Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// NotFoundPlan returns the plan of a configured package, or package wildcard,
+// that is not part of the build.
+func NotFoundPlan(pkgPath string) *PackagePlan {
+	plan := &PackagePlan{PkgPath: pkgPath}
+	signatrues := append([]string{}, configs.ConfigData.Hookpoints[pkgPath]...)
+	sort.Strings(signatrues)
+	for _, signatrue := range signatrues {
+		plan.Hookpoints = append(plan.Hookpoints, PlannedHookpoint{
+			Signature: signatrue,
+			Status:    StatusNotFound,
+			Reason:    "package not part of the build",
+		})
+	}
+	return plan
+}
+
+// MainPackagePlan returns the hookpoints of the package wildcards matching the
+// import path of a main package, nil when there is none. Main packages are
+// compiled with the package path `main` so package wildcards never match them.
+func MainPackagePlan(importPath string) *PackagePlan {
+	plan := &PackagePlan{PkgPath: importPath}
+	for _, w := range configs.PackageWildcards {
+		if !w.Match(importPath) {
+			continue
+		}
+		for _, hookpoint := range w.Hookpoints {
+			plan.Hookpoints = append(plan.Hookpoints, PlannedHookpoint{
+				Signature: w.Pattern + ": " + hookpoint,
+				Status:    StatusIgnored,
+				Reason:    mainPackageReason,
+			})
+		}
+	}
+	if len(plan.Hookpoints) == 0 {
+		return nil
+	}
+	return plan
+}
+
+const mainPackageReason = "package wildcards don't match main packages, configure their hookpoints in the `main` package"
+
+// Sorted list of the signatures configured for the package, including the
+// ones of the package wildcards it matches.
+func configuredSignatrues(pkgPath string) []string {
+	signatrues := configs.PackageHookpoints(pkgPath)
+	sort.Strings(signatrues)
+	return signatrues
+}
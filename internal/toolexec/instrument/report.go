@@ -0,0 +1,156 @@
+package instrument
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ListenOcean/goHookTool/configs"
+)
+
+// ReportFilepath is the path of the JSON instrumentation report written at the
+// end of the build. No report is written when empty.
+var ReportFilepath string
+
+// Report is the instrumentation report of a build.
+type Report struct {
+	Version  string           `json:"version"`
+	Packages []*PackageReport `json:"packages"`
+	// Configured hookpoints missing from the hook table.
+	Missing []PlannedHookpoint `json:"missing"`
+	// Hook descriptor functions of the final hook table.
+	HookTable []string `json:"hookTable"`
+}
+
+// PackageReport is the instrumentation report of a package.
+type PackageReport struct {
+	PkgPath      string             `json:"package"`
+	Files        []string           `json:"files"`
+	Instrumented []InstrumentedFunc `json:"instrumented"`
+	Skipped      []SkippedFunc      `json:"skipped"`
+	Missing      []PlannedHookpoint `json:"missing"`
+	// Configured patterns and the functions they expanded to.
+	Patterns []PlannedHookpoint `json:"patterns"`
+}
+
+type InstrumentedFunc struct {
+	Signature string `json:"signature"`
+	HookID    string `json:"hookId"`
+	// Interface methods the method was instrumented for.
+	Interfaces []string `json:"interfaces,omitempty"`
+}
+
+type SkippedFunc struct {
+	Signature string `json:"signature"`
+	Reason    string `json:"reason"`
+}
+
+func (h *packageInstrumentationHelper) packageReport() *PackageReport {
+	r := &PackageReport{
+		PkgPath:      h.pkgPath,
+		Files:        []string{},
+		Instrumented: []InstrumentedFunc{},
+		Skipped:      []SkippedFunc{},
+		Missing:      []PlannedHookpoint{},
+		Patterns:     []PlannedHookpoint{},
+	}
+	for src := range h.parsedFiles {
+		r.Files = append(r.Files, src)
+	}
+	sort.Strings(r.Files)
+	for _, hook := range h.stats.instrumented {
+		r.Instrumented = append(r.Instrumented, InstrumentedFunc{
+			Signature:  hook.Signature,
+			HookID:     hook.ID,
+			Interfaces: h.stats.implementations[hook.Signature],
+		})
+	}
+	for _, ignored := range h.stats.ignored {
+		r.Skipped = append(r.Skipped, SkippedFunc{Signature: ignored.signatrue, Reason: ignored.reason})
+	}
+	for _, hookpoint := range h.plannedHookpoints(configuredSignatrues(h.pkgPath)) {
+		if hookpoint.Status != StatusInstrumented {
+			r.Missing = append(r.Missing, hookpoint)
+		} else if hookpoint.Expanded != nil {
+			r.Patterns = append(r.Patterns, hookpoint)
+		}
+	}
+	return r
+}
+
+// AppendPackageReport adds the report of the instrumented package to the
+// report list file of the project build directory, later read when compiling
+// the main package.
+func AppendPackageReport(i Instrumenter, packageBuildDir string) error {
+	data, err := json.Marshal(i.(helperGetter).helper().packageReport())
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(getReportListFilepath(ProjectBuildDir(packageBuildDir)), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// A single write so that concurrent compilations don't interleave lines.
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// writeReport writes the final report made of the package reports, the
+// missing hookpoints and the hook table.
+func writeReport(reportFilepath string, packages []*PackageReport, hooks []string, missing []PlannedHookpoint) error {
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].PkgPath < packages[j].PkgPath
+	})
+	hookTable := append([]string{}, hooks...)
+	sort.Strings(hookTable)
+	if missing == nil {
+		missing = []PlannedHookpoint{}
+	}
+
+	data, err := json.MarshalIndent(&Report{
+		Version:   configs.Version,
+		Packages:  packages,
+		Missing:   missing,
+		HookTable: hookTable,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(reportFilepath, append(data, '\n'), 0644)
+}
+
+func getReportListFilepath(dir string) string {
+	return filepath.Join(dir, "report.jsonl")
+}
+
+func readReportListFile(reportListFilepath string) (packages []*PackageReport, err error) {
+	f, err := os.Open(reportListFilepath)
+	if os.IsNotExist(err) {
+		return []*PackageReport{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	// Package reports can be larger than the default line limit.
+	scanner.Buffer(nil, 64*1024*1024)
+	// Packages can also be compiled by the nested build of the packages
+	// imported by the code snippets.
+	seen := make(map[string]bool)
+	for scanner.Scan() {
+		var r PackageReport
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, err
+		}
+		if seen[r.PkgPath] {
+			continue
+		}
+		seen[r.PkgPath] = true
+		packages = append(packages, &r)
+	}
+	return packages, scanner.Err()
+}
@@ -0,0 +1,45 @@
+package instrument
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Strict makes the build fail when configured hookpoints are not instrumented.
+var Strict bool
+
+// UnmatchedHookpoints returns the hookpoints configured for the instrumented
+// package that didn't get instrumented.
+func UnmatchedHookpoints(i Instrumenter) []PlannedHookpoint {
+	h := i.(helperGetter).helper()
+	var unmatched []PlannedHookpoint
+	for _, hookpoint := range h.plannedHookpoints(configuredSignatrues(h.pkgPath)) {
+		if hookpoint.Status != StatusInstrumented {
+			unmatched = append(unmatched, hookpoint)
+		}
+	}
+	return unmatched
+}
+
+// UnmatchedHookpointsError is the strict mode error listing the configured
+// hookpoints that were not instrumented.
+type UnmatchedHookpointsError struct {
+	PkgPath    string
+	Hookpoints []PlannedHookpoint
+}
+
+func (e *UnmatchedHookpointsError) Error() string {
+	var msg strings.Builder
+	if e.PkgPath != "" {
+		fmt.Fprintf(&msg, "autobuild: strict mode: %d configured hookpoint(s) of package `%s` not instrumented:", len(e.Hookpoints), e.PkgPath)
+	} else {
+		fmt.Fprintf(&msg, "autobuild: strict mode: %d configured hookpoint(s) not instrumented:", len(e.Hookpoints))
+	}
+	for _, hookpoint := range e.Hookpoints {
+		fmt.Fprintf(&msg, "\n\t%s: %s (%s)", hookpoint.Signature, hookpoint.Status, hookpoint.Reason)
+		if len(hookpoint.Closest) > 0 {
+			fmt.Fprintf(&msg, "\n\t\tclosest existing functions: %s", strings.Join(hookpoint.Closest, ", "))
+		}
+	}
+	return msg.String()
+}
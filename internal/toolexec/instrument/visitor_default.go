@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"path/filepath"
 	"sort"
 	"strings"
 
@@ -17,12 +18,12 @@ import (
 )
 
 type instrumentationVisitorFace interface {
-	instrument(root *dst.Package) (instrumented []*dst.File)
+	instrument(root *dst.Package) (instrumented []*dst.File, err error)
 }
 
 type defaultPackageInstrumentationVisitor struct {
 	// Instrumentation statistics of the currently instrumented package.
-	stats instrumentationStats
+	stats *instrumentationStats
 	// Package path being instrumented. Used to generate unique hook names
 	// prefixed by the package path.
 	pkgPath string
@@ -44,28 +45,89 @@ type defaultPackageInstrumentationVisitor struct {
 	// The hook descriptor type declaration added once per instrumented package
 	// and used by hook descriptor functions to return a value of that type.
 	hookDescriptorTypeDecl *dst.GenDecl
+	// Interface methods implemented by the methods of the package, by method
+	// signature.
+	interfaceMethods map[string][]string
+	// Callsite instrumentation of the package, nil when no callsites are
+	// configured.
+	callsites *callsiteInstrumentation
+	// True when the callsites of the current file got rewritten.
+	rewritten bool
+	// Base names of the package files, and the ones of the current file
+	// along with the number of its init functions visited so far.
+	filenames map[*dst.File]string
+	filename  string
+	inits     int
+	// First error met while instrumenting, stopping the instrumentation.
+	err error
 }
 
 type instrumentationStats struct {
-	ignored []string
+	// Signatures of every function declaration of the package.
+	funcs []string
+	// Function declarations ignored by ast.ShouldIgnoreFuncDecl, along with
+	// the reason why.
+	ignored []ignoredFuncDecl
+	// Hookpoints of the instrumented function declarations.
+	instrumented []*ast.Hookpoint
+	// Signatures of the instrumented functions by the configured patterns
+	// they matched.
+	expanded map[string][]string
+	// Interface methods implemented by the instrumented methods, by method
+	// signature.
+	implementations map[string][]string
+}
+
+type ignoredFuncDecl struct {
+	signatrue string
+	reason    string
 }
 
-func (s *instrumentationStats) addIgnored(funcDecl *dst.FuncDecl) {
-	s.ignored = append(s.ignored, funcDecl.Name.Name)
+func (s *instrumentationStats) addFunc(signatrue string) {
+	s.funcs = append(s.funcs, signatrue)
 }
 
-func newDefaultPackageInstrumentationVisitor(pkgPath string, instrumentedFiles map[*dst.File][]*ast.Hookpoint) *defaultPackageInstrumentationVisitor {
-	utils.NotNil(instrumentedFiles)
+func (s *instrumentationStats) addInstrumented(hook *ast.Hookpoint, hookpoints []string) {
+	s.instrumented = append(s.instrumented, hook)
+	for _, hookpoint := range hookpoints {
+		if hookpoint == hook.Signature {
+			continue
+		}
+		if s.expanded == nil {
+			s.expanded = make(map[string][]string)
+		}
+		s.expanded[hookpoint] = append(s.expanded[hookpoint], hook.Signature)
+	}
+}
+
+func (s *instrumentationStats) addImplementation(signatrue string, interfaces []string) {
+	if s.implementations == nil {
+		s.implementations = make(map[string][]string)
+	}
+	s.implementations[signatrue] = interfaces
+}
+
+func (s *instrumentationStats) addIgnored(signatrue, reason string) {
+	s.ignored = append(s.ignored, ignoredFuncDecl{signatrue: signatrue, reason: reason})
+}
+
+func newDefaultPackageInstrumentationVisitor(pkgPath string, instrumentedFiles map[*dst.File][]*ast.Hookpoint, stats *instrumentationStats) *defaultPackageInstrumentationVisitor {
+	utils.NotNil(instrumentedFiles, stats)
 
 	hookDescriptorTypeDecl, hookDescriptorTypeSpec, newDescriptorValueInitializer := ast.NewHookDescriptorType()
 	hookDescriptorTypeIdent := hookDescriptorTypeSpec.Name.Name
-	return &defaultPackageInstrumentationVisitor{
+	v := &defaultPackageInstrumentationVisitor{
+		stats:                             stats,
 		pkgPath:                           pkgPath,
 		instrumentedHooks:                 instrumentedFiles,
 		hookDescriptorTypeIdent:           hookDescriptorTypeIdent,
 		hookDescriptorTypeDecl:            hookDescriptorTypeDecl,
 		newHookDescriptorValueInitializer: newDescriptorValueInitializer,
 	}
+	if configs.HasCallsites() {
+		v.callsites = newCallsiteInstrumentation(pkgPath, newDescriptorValueInitializer)
+	}
+	return v
 }
 
 func (v *defaultPackageInstrumentationVisitor) makeSignatrue(funcDecl *dst.FuncDecl) string {
@@ -73,45 +135,104 @@ func (v *defaultPackageInstrumentationVisitor) makeSignatrue(funcDecl *dst.FuncD
 	res.WriteString(v.pkgPath)
 	if funcDecl.Recv != nil {
 		utils.True(len(funcDecl.Recv.List) == 1)
-		switch funcDecl.Recv.List[0].Type.(type) {
-		case *dst.StarExpr:
+		name, pointer, generic := ast.ReceiverType(funcDecl)
+		if generic {
+			name += configs.TypeParamsSignatrue
+		}
+		if pointer {
 			res.WriteString(".(*")
-			res.WriteString(funcDecl.Recv.List[0].Type.(*dst.StarExpr).X.(*dst.Ident).Name)
+			res.WriteString(name)
 			res.WriteString(")")
-		case *dst.Ident:
+		} else {
 			res.WriteString(".")
-			res.WriteString(funcDecl.Recv.List[0].Type.(*dst.Ident).Name)
+			res.WriteString(name)
 		}
 	}
 	res.WriteString(".")
 	res.WriteString(funcDecl.Name.Name)
+	if funcDecl.Type.TypeParams != nil && len(funcDecl.Type.TypeParams.List) > 0 {
+		res.WriteString(configs.TypeParamsSignatrue)
+	}
 	return res.String()
 }
 
 func (v *defaultPackageInstrumentationVisitor) instrumentFuncDeclPre(funcDecl *dst.FuncDecl) {
 	signatrue := v.makeSignatrue(funcDecl)
-	if ast.ShouldIgnoreFuncDecl(funcDecl) {
-		v.stats.addIgnored(funcDecl)
+	v.stats.addFunc(signatrue)
+	if reason := ast.FuncDeclIgnoredReason(funcDecl); reason != "" {
+		if reason == ast.InitIgnoredReason && ast.IsInitFuncDecl(funcDecl) && configs.IsInitHookpoint(v.pkgPath) {
+			v.instrumentInitFuncDecl(funcDecl, signatrue)
+			return
+		}
+		v.stats.addIgnored(signatrue, reason)
 		return
 	}
 
-	if signatrueSet, ok := configs.HookPointMap[v.pkgPath]; ok {
-		if _, ok := signatrueSet[signatrue]; ok {
-			log.Printf("Will hook: %s\n", signatrue)
-			hook := ast.NewHookpoint(signatrue, v.pkgPath, funcDecl, v.hookDescriptorTypeIdent, v.newHookDescriptorValueInitializer)
-			v.instrumented = append(v.instrumented, hook)
-			funcDecl.Body.List = append([]dst.Stmt{hook.InstrumentationStmt}, funcDecl.Body.List...)
+	hookpoints := configs.MatchHookpoints(v.pkgPath, signatrue)
+	if interfaces := v.interfaceMethods[signatrue]; len(interfaces) > 0 {
+		hookpoints = append(hookpoints, interfaces...)
+		v.stats.addImplementation(signatrue, interfaces)
+		if _, ok := configs.ConfigData.Codes[signatrue]; !ok {
+			// The implementation uses the code snippet of the interface method
+			for _, iface := range interfaces {
+				if code, ok := configs.ConfigData.Codes[iface]; ok {
+					configs.ConfigData.Codes[signatrue] = code
+					break
+				}
+			}
 		}
 	}
+	if len(hookpoints) > 0 {
+		log.Printf("Will hook: %s\n", signatrue)
+		hook, err := ast.NewHookpoint(signatrue, v.pkgPath, funcDecl, v.hookDescriptorTypeIdent, v.newHookDescriptorValueInitializer)
+		if err != nil {
+			v.err = err
+			return
+		}
+		v.instrumented = append(v.instrumented, hook)
+		v.stats.addInstrumented(hook, hookpoints)
+		funcDecl.Body.List = append([]dst.Stmt{hook.InstrumentationStmt}, funcDecl.Body.List...)
+	}
+}
+
+// instrumentInitFuncDecl instruments a package init function, having a
+// distinct hook ID per file and index in the file.
+func (v *defaultPackageInstrumentationVisitor) instrumentInitFuncDecl(funcDecl *dst.FuncDecl, signatrue string) {
+	index := v.inits
+	v.inits++
+	log.Printf("Will hook: %s (%s #%d)\n", signatrue, v.filename, index)
+	hook, err := ast.NewInitHookpoint(signatrue, v.pkgPath, v.filename, index, funcDecl, v.newHookDescriptorValueInitializer)
+	if err != nil {
+		v.err = err
+		return
+	}
+	v.instrumented = append(v.instrumented, hook)
+	v.stats.addInstrumented(hook, []string{signatrue})
+	funcDecl.Body.List = append([]dst.Stmt{hook.InstrumentationStmt}, funcDecl.Body.List...)
 }
 
-func (v *defaultPackageInstrumentationVisitor) instrument(root *dst.Package) (instrumented []*dst.File) {
+func (v *defaultPackageInstrumentationVisitor) instrument(root *dst.Package) (instrumented []*dst.File, err error) {
+	v.filenames = make(map[*dst.File]string, len(root.Files))
+	for filename, file := range root.Files {
+		v.filenames[file] = filepath.Base(filename)
+	}
 	dstutil.Apply(root, v.instrumentPre, v.instrumentPost)
-	return v.instrumentedFiles
+	if v.err != nil {
+		return nil, v.err
+	}
+	return v.instrumentedFiles, nil
 }
 
 func (v *defaultPackageInstrumentationVisitor) instrumentPre(cursor *dstutil.Cursor) bool {
+	if v.err != nil {
+		return false
+	}
 	switch node := cursor.Node().(type) {
+	case *dst.File:
+		v.filename, v.inits = v.filenames[node], 0
+		if v.callsites != nil {
+			v.rewritten, v.err = v.callsites.rewriteFile(node)
+		}
 	case *dst.FuncDecl:
 		v.instrumentFuncDeclPre(node)
 		// Note that we don't add the file metadata here in order to avoid to
@@ -132,6 +253,20 @@ func (v *defaultPackageInstrumentationVisitor) instrumentPost(cursor *dstutil.Cu
 }
 
 func (v *defaultPackageInstrumentationVisitor) instrumentFilePost(file *dst.File) {
+	if v.rewritten {
+		v.rewritten = false
+		// Declare the callsite wrappers referenced for the first time
+		for _, w := range v.callsites.undeclaredWrappers() {
+			file.Decls = append(file.Decls, w.funcDecl)
+			v.instrumented = append(v.instrumented, w.hook)
+			v.stats.addInstrumented(w.hook, []string{w.hook.Signature})
+		}
+		if len(v.instrumented) == 0 {
+			// Only references to wrappers declared by other files
+			v.instrumentedFiles = append(v.instrumentedFiles, file)
+			return
+		}
+	}
 	if len(v.instrumented) == 0 {
 		// Nothing got instrumented
 		return
@@ -149,6 +284,7 @@ func (v *defaultPackageInstrumentationVisitor) instrumentFilePost(file *dst.File
 
 func (v *defaultPackageInstrumentationVisitor) addFileMetadata(file *dst.File, instrumented []*ast.Hookpoint) {
 	ast.AddUnsafePackageImport(file)
+	ast.AddSnippetImports(file, instrumented)
 	// runtime包不需要再次引入_atomic_load_pointer的定义（addAtomicLoadFuncDecl）
 	if v.pkgPath == "runtime" {
 		v.fileMetadataOnce = true
@@ -176,6 +312,7 @@ func (v *defaultPackageInstrumentationVisitor) addHookMetadata(file *dst.File, h
 	v.addHookPrologVarDecl(file, h)
 	v.addHookPrologLoadFuncDecl(file, h)
 	v.addHookDescriptorFuncDecl(file, h)
+	file.Decls = append(file.Decls, h.Decls...)
 }
 
 func (v *defaultPackageInstrumentationVisitor) addHookPrologVarDecl(file *dst.File, h *ast.Hookpoint) {
@@ -191,9 +328,19 @@ func (v *defaultPackageInstrumentationVisitor) addHookDescriptorType(file *dst.F
 }
 
 // Write into `w` the Go sources of the hook table for the list of hook
-// descriptor function `hooks`.
-func writeHookTable(w io.Writer, hooks []string) error {
-	sort.Strings(hooks)
+// descriptor function `hooks`, along with the signatures of the methods
+// instrumented for each interface method.
+func writeHookTable(w io.Writer, hooks []string, implementations map[string][]string) error {
+	// The hooks are looked up by symbol: keep the callsite hooks next to the
+	// hook of the function they call, and the init function hooks of a
+	// package together.
+	sort.Slice(hooks, func(i, j int) bool {
+		a, b := hookTableKey(hooks[i]), hookTableKey(hooks[j])
+		if a != b {
+			return a < b
+		}
+		return hooks[i] < hooks[j]
+	})
 
 	// In case the hook descriptor type hasn't been created, we recreate the
 	// type alias again in the hook table file and with a distinct name.
@@ -205,15 +352,29 @@ type _hook_table_type = []func(*_hook_table_hook_descriptor_type)
 type _instrumentation_descriptor_type = struct {
 	Version   string
 	HookTable _hook_table_type
+	Interfaces []struct {
+		Method          string
+		Implementations []string
+	}
 }
 
 //go:linkname _instrumentation_descriptor _instrumentation_descriptor
 var _instrumentation_descriptor = &_instrumentation_descriptor_type{
 	Version: %q,
 	HookTable: _hook_table_array,
+	Interfaces: _interface_implementations_array,
+}
+
+var _interface_implementations_array = []struct {
+	Method          string
+	Implementations []string
+}{%s
 }
 `
 		tableInitListEntryFormat = "\n\t%s,"
+		// Slices and strings only, so that the descriptor is statically
+		// initialized and usable by package init functions.
+		interfaceImplementationsEntryFormat = "\n\t{%q, %#v},"
 
 		hookDescriptorForwardFuncDeclFormat = `//go:linkname %[1]s %[1]s
 func %[1]s(*_hook_table_hook_descriptor_type)
@@ -223,7 +384,7 @@ func %[1]s(*_hook_table_hook_descriptor_type)
 
 import _ "unsafe"
 
-type _hook_table_hook_descriptor_type = struct {	Func, Prolog interface{} }
+type _hook_table_hook_descriptor_type = %s
 
 %s
 
@@ -232,6 +393,9 @@ type _hook_table_hook_descriptor_type = struct {	Func, Prolog interface{} }
 	)
 
 	var tableInitList, hookDescriptorForwardFuncDecls bytes.Buffer
+	// The hook descriptor functions of the main package use its hook
+	// descriptor type.
+	hookDescriptorType := "struct {	Func, Prolog interface{} }"
 
 	for _, hookDescriptorFuncName := range hooks {
 		// Create the hook table initializer entry line
@@ -244,6 +408,7 @@ type _hook_table_hook_descriptor_type = struct {	Func, Prolog interface{} }
 		// declare the hook descriptor functions that are defined in the main
 		// package.
 		if ast.IsHookDescriptorFuncInMainPackage(hookDescriptorFuncName) {
+			hookDescriptorType = configs.HookDescriptorTypeIdent
 			continue
 		}
 
@@ -254,7 +419,27 @@ type _hook_table_hook_descriptor_type = struct {	Func, Prolog interface{} }
 		}
 	}
 
-	hookTableVar := fmt.Sprintf(tableFormat, &tableInitList, configs.Version)
-	_, err := io.WriteString(w, fmt.Sprintf(fileFormat, &hookDescriptorForwardFuncDecls, hookTableVar))
+	// Sorted by interface method so that they are binary-searched.
+	methods := make([]string, 0, len(implementations))
+	for method := range implementations {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	var interfaceImplementations bytes.Buffer
+	for _, method := range methods {
+		signatrues := implementations[method]
+		sort.Strings(signatrues)
+		fmt.Fprintf(&interfaceImplementations, interfaceImplementationsEntryFormat, method, signatrues)
+	}
+
+	hookTableVar := fmt.Sprintf(tableFormat, &tableInitList, configs.Version, &interfaceImplementations)
+	_, err := io.WriteString(w, fmt.Sprintf(fileFormat, hookDescriptorType, &hookDescriptorForwardFuncDecls, hookTableVar))
 	return err
 }
+
+// hookTableKey returns the key the hook descriptor function is sorted by in
+// the hook table.
+func hookTableKey(hookDescriptorFuncName string) string {
+	id := strings.TrimPrefix(hookDescriptorFuncName, configs.HookDescriptorIdentPrefix)
+	return configs.InitTargetID(configs.CallsiteTargetID(id))
+}
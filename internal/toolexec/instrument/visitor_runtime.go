@@ -13,13 +13,14 @@ type runtimeInstrumentationVisitor struct {
 	defaultVisitor *defaultPackageInstrumentationVisitor
 }
 
-func newRuntimeInstrumentationVisitor(pkgPath string, instrumentedFiles map[*dst.File][]*ast.Hookpoint) *runtimeInstrumentationVisitor {
-	utils.NotNil(instrumentedFiles)
+func newRuntimeInstrumentationVisitor(pkgPath string, instrumentedFiles map[*dst.File][]*ast.Hookpoint, stats *instrumentationStats) *runtimeInstrumentationVisitor {
+	utils.NotNil(instrumentedFiles, stats)
 
 	hookDescriptorTypeDecl, hookDescriptorTypeSpec, newDescriptorValueInitializer := ast.NewHookDescriptorType()
 	hookDescriptorTypeIdent := hookDescriptorTypeSpec.Name.Name
 	return &runtimeInstrumentationVisitor{
 		defaultVisitor: &defaultPackageInstrumentationVisitor{
+			stats:                             stats,
 			pkgPath:                           pkgPath,
 			instrumentedHooks:                 instrumentedFiles,
 			hookDescriptorTypeIdent:           hookDescriptorTypeIdent,
@@ -29,8 +30,11 @@ func newRuntimeInstrumentationVisitor(pkgPath string, instrumentedFiles map[*dst
 	}
 }
 
-func (v *runtimeInstrumentationVisitor) instrument(root *dst.Package) []*dst.File {
+func (v *runtimeInstrumentationVisitor) instrument(root *dst.Package) ([]*dst.File, error) {
 	dstutil.Apply(root, v.defaultVisitor.instrumentPre, v.defaultVisitor.instrumentPost)
+	if v.defaultVisitor.err != nil {
+		return nil, v.defaultVisitor.err
+	}
 
 	instrumentedFileSet := make(map[*dst.File]struct{})
 	for _, eachFile := range v.defaultVisitor.instrumentedFiles {
@@ -41,5 +45,5 @@ func (v *runtimeInstrumentationVisitor) instrument(root *dst.Package) []*dst.Fil
 	for eachFile := range instrumentedFileSet {
 		instrumentedFiles = append(instrumentedFiles, eachFile)
 	}
-	return instrumentedFiles
+	return instrumentedFiles, nil
 }
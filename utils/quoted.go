@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SplitQuoted splits s into space-separated fields, which can be quoted with
+// single or double quotes, as the go command does with -toolexec.
+func SplitQuoted(s string) (fields []string, err error) {
+	var field []rune
+	var quote rune
+	inField := false
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				field = append(field, r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inField = true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inField {
+				fields = append(fields, string(field))
+				field, inField = field[:0], false
+			}
+		default:
+			field = append(field, r)
+			inField = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inField {
+		fields = append(fields, string(field))
+	}
+	return fields, nil
+}
+
+// JoinQuoted joins the fields with spaces, quoting the ones which need it, so
+// that SplitQuoted and the go command return them. A field having both single
+// and double quotes cannot be quoted: the go command has no escapes.
+func JoinQuoted(fields []string) (string, error) {
+	quoted := make([]string, len(fields))
+	for i, field := range fields {
+		switch {
+		case strings.Contains(field, "'") && strings.Contains(field, `"`):
+			return "", fmt.Errorf("argument `%s` contains both single and double quotes and cannot be quoted", field)
+		case strings.Contains(field, "'"):
+			quoted[i] = `"` + field + `"`
+		case field == "" || strings.ContainsAny(field, " \t\n\r\""):
+			quoted[i] = "'" + field + "'"
+		default:
+			quoted[i] = field
+		}
+	}
+	return strings.Join(quoted, " "), nil
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJoinQuoted(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		fields  []string
+		wantErr bool
+	}{
+		{name: "none"},
+		{name: "plain", fields: []string{"/bin/autobuild", "toolexec", "-config=a.yaml"}},
+		{name: "empty", fields: []string{"a", "", "b"}},
+		{name: "spaces", fields: []string{"-config=/my dir/a.yaml", "a\tb", "a\nb", "a\rb"}},
+		{name: "single quote", fields: []string{"it's"}},
+		{name: "single quote and spaces", fields: []string{"-ldflags=-X 'main.v=a b'"}},
+		{name: "double quote", fields: []string{`say "hi"`, `a"b`}},
+		{name: "both quotes", fields: []string{`it's "x"`}, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			joined, err := JoinQuoted(tc.fields)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("got %q, want an error", joined)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := SplitQuoted(joined)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.fields) {
+				t.Errorf("%q splits into %q, want %q", joined, got, tc.fields)
+			}
+		})
+	}
+}
+
+func TestSplitQuoted(t *testing.T) {
+	for _, tc := range []struct {
+		s       string
+		want    []string
+		wantErr bool
+	}{
+		{s: "", want: nil},
+		{s: "  a  b\t", want: []string{"a", "b"}},
+		{s: `'a b' "c d"`, want: []string{"a b", "c d"}},
+		{s: `-X='a "b"'`, want: []string{`-X=a "b"`}},
+		{s: `''`, want: []string{""}},
+		{s: `'a`, wantErr: true},
+	} {
+		t.Run(tc.s, func(t *testing.T) {
+			got, err := SplitQuoted(tc.s)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("got %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}